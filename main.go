@@ -22,13 +22,24 @@ func initialize() {
 	}
 
 	rcsm.ReadConfig()
+	rcsm.SetupLogging()
 
-	rcsm.TriggerLogEvent("info", "rcsm", fmt.Sprintf("Starting rcsm (RedCraft Server Manager) v%s", rcsm.Version))
+	rcsm.TriggerEvent(rcsm.EventRcsmStarted, "info", "rcsm", fmt.Sprintf("Starting rcsm (RedCraft Server Manager) v%s", rcsm.Version), nil)
 
 	if rcsm.RedisEnabled {
 		rcsm.RedisConnect()
 	}
 
+	err := rcsm.ConnectMessageBus()
+	if err != nil {
+		rcsm.TriggerLogEvent("fatal", "bus", fmt.Sprintf("Could not connect to the message bus: %s", err))
+		os.Exit(1)
+	}
+
+	if rcsm.ProxyRegistrationEnabled {
+		rcsm.StartProxyRegistration()
+	}
+
 	rcsm.CreateMissingServers()
 	rcsm.DiscoverServers()
 
@@ -43,18 +54,41 @@ func initialize() {
 	if rcsm.AutoUpdateEnabled {
 		rcsm.StartUpdateChecks()
 	}
+
+	if rcsm.RedisEnabled && rcsm.RedisLeaderElectionEnabled {
+		rcsm.StartLeaderElection()
+	}
+
+	if rcsm.RedisEnabled && rcsm.RedisRegistryEnabled {
+		rcsm.StartRedisRegistry()
+	}
+
+	if rcsm.WebhooksEnabled && rcsm.WebhooksStatusEnabled {
+		rcsm.StartDiscordStatus()
+	}
 }
 
 func stop() {
-	rcsm.TriggerLogEvent("info", "rcsm", fmt.Sprintf("Stopping rcsm (RedCraft Server Manager) v%s", rcsm.Version))
+	rcsm.TriggerEvent(rcsm.EventRcsmStopped, "info", "rcsm", fmt.Sprintf("Stopping rcsm (RedCraft Server Manager) v%s", rcsm.Version), nil)
 
 	if rcsm.AutoStopOnClose {
 		rcsm.StopAllServers()
 	}
+
+	if rcsm.RedisEnabled && rcsm.RedisRegistryEnabled {
+		rcsm.ClearRedisRegistry()
+	}
+
+	if rcsm.RedisEnabled && rcsm.RedisLeaderElectionEnabled {
+		rcsm.ReleaseLeadership()
+	}
+
+	rcsm.FlushEvents()
 }
 
 func waitForQuitSignal() {
-	exitSignal := make(chan os.Signal)
+	// signal.Notify doesn't block, a signal received before the channel is read would be lost without a buffer
+	exitSignal := make(chan os.Signal, 1)
 	signal.Notify(exitSignal, syscall.SIGINT, syscall.SIGTERM)
 	<-exitSignal
 }
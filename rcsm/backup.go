@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -30,30 +31,35 @@ var (
 func BackupServerS3(serverName string, directoriesToBackup []string) {
 	serverPath := path.Join(MinecraftServersDirectory, serverName)
 	backupFileName := fmt.Sprintf("%s.tar.gz", serverName)
+	startTime := time.Now()
 
 	// Create a .tar.gz file from the temporary file
 	var buf bytes.Buffer
 	compress(serverPath, &buf, directoriesToBackup)
+	backupSize := buf.Len()
 
 	// Create a temporary file to copy the directories to backup
 	tempFile, err := ioutil.TempFile("", backupFileName)
 	if err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Unable to create temporary file for backup: %s", err))
+		triggerBackupFailedEvent(serverName, fmt.Errorf("Unable to create temporary file for backup: %s", err))
 		return
 	}
 
 	fileToWrite, err := os.OpenFile(tempFile.Name(), os.O_CREATE|os.O_RDWR, os.FileMode(600))
 	if err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Unable to open temporary file for backup: %s", err))
+		triggerBackupFailedEvent(serverName, fmt.Errorf("Unable to open temporary file for backup: %s", err))
 		return
 	}
 	if _, err := io.Copy(fileToWrite, &buf); err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Unable to write to temporary file for backup: %s", err))
+		triggerBackupFailedEvent(serverName, fmt.Errorf("Unable to write to temporary file for backup: %s", err))
 		return
 	}
 
 	// Upload the backup to S3
-	uploadBackup(serverName, tempFile.Name())
+	err = uploadBackup(serverName, tempFile.Name())
+	if err != nil {
+		triggerBackupFailedEvent(serverName, err)
+	}
 
 	// Delete the temporary file
 	if err := os.Remove(tempFile.Name()); err != nil {
@@ -61,10 +67,36 @@ func BackupServerS3(serverName string, directoriesToBackup []string) {
 		return
 	}
 
-	TriggerLogEvent("info", serverName, "Backup complete")
+	if err == nil {
+		updateServerState(serverName, func(state *serverState) {
+			state.LastBackup = time.Now().Unix()
+		})
+
+		TriggerEvent(EventBackupCompleted, "info", serverName, "Backup complete", EventFields{
+			"server":   serverName,
+			"bytes":    backupSize,
+			"duration": time.Since(startTime).Seconds(),
+		})
+	}
+}
+
+// getLastBackup returns the time of the last successful backup of a server, it's kept across restarts
+func getLastBackup(serverName string) (time.Time, bool) {
+	state, found := getServerState(serverName)
+	if !found || state.LastBackup == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(state.LastBackup, 0), true
+}
+
+func triggerBackupFailedEvent(serverName string, err error) {
+	TriggerEvent(EventBackupFailed, "severe", serverName, err.Error(), EventFields{
+		"server": serverName,
+		"error":  err.Error(),
+	})
 }
 
-func uploadBackup(serverName string, archivePath string) {
+func uploadBackup(serverName string, archivePath string) error {
 	_, uploader := getS3BackupClient()
 
 	s3Bucket := S3BackupBucket
@@ -73,9 +105,9 @@ func uploadBackup(serverName string, archivePath string) {
 
 	file, err := os.Open(archivePath)
 	if err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Unable to open backup file for upload: %s", err))
-		return
+		return fmt.Errorf("Unable to open backup file for upload: %s", err)
 	}
+	defer file.Close()
 
 	TriggerLogEvent("info", serverName, fmt.Sprintf("Uploading backup to %s", s3Location))
 
@@ -86,9 +118,10 @@ func uploadBackup(serverName string, archivePath string) {
 		Body:   file,
 	})
 	if err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Unable to upload %q to %q, %v", backupFileName, s3Location, err))
-		return
+		return fmt.Errorf("Unable to upload %q to %q, %v", backupFileName, s3Location, err)
 	}
+
+	return nil
 }
 
 func compress(src string, buf io.Writer, directoriesToBackup []string) error {
@@ -161,8 +194,8 @@ func getS3BackupClient() (*s3.S3, *s3manager.Uploader) {
 	if s3BackupClient == nil || s3BackupUploader == nil {
 		s3Session, err := session.NewSession(&aws.Config{
 			Credentials: credentials.NewStaticCredentials(AWSBackupAccessKeyID, AWSBackupSecretAccessKey, ""),
-			Region:   aws.String(S3BackupRegion),
-			Endpoint: aws.String(S3BackupEndpoint),
+			Region:      aws.String(S3BackupRegion),
+			Endpoint:    aws.String(S3BackupEndpoint),
 		})
 		if err != nil {
 			TriggerLogEvent("fatal", "setup", fmt.Sprintf("Could not create an S3 backup client: %s", err))
@@ -0,0 +1,95 @@
+package rcsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MessageBus defines a transport for events and commands, such as Redis, NATS or MQTT
+type MessageBus interface {
+	// Name is used in logs and as the name of the event sink
+	Name() string
+	// Available returns true if the bus is connected and messages can be published
+	Available() bool
+	// Publish sends a message on a channel, a subject for NATS or a topic for MQTT
+	Publish(channel string, payload string) error
+	// Subscribe calls the callback with the channel and the payload of every message received on a channel
+	Subscribe(channel string, callback callbackFunc) error
+}
+
+// Bus is the message bus used for events and commands, nil if disabled
+var Bus MessageBus
+
+// ConnectMessageBus connects the bus set with MessageBusType, Redis must be connected first if it's used
+func ConnectMessageBus() error {
+	if !isMessageBusEnabled() {
+		return nil
+	}
+
+	var err error
+	switch getMessageBusName() {
+	case "redis":
+		Bus = redisBus{}
+	case "nats":
+		Bus, err = connectNATS()
+	case "mqtt":
+		Bus, err = connectMQTT()
+	default:
+		err = fmt.Errorf("unknown MESSAGE_BUS %s, it should be redis, nats or mqtt", MessageBusType)
+	}
+
+	return err
+}
+
+// isMessageBusEnabled returns true if events and commands are sent on a message bus, Redis is only used if it's enabled
+func isMessageBusEnabled() bool {
+	return getMessageBusName() != "redis" || RedisEnabled
+}
+
+func getMessageBusName() string {
+	return strings.ToLower(MessageBusType)
+}
+
+// SendBusEvent sends an event on the message bus
+func SendBusEvent(event Event) error {
+	if Bus == nil {
+		return fmt.Errorf("the message bus is not connected yet")
+	}
+
+	requestPayload, err := json.Marshal(newRedisMessage(event))
+	if err != nil {
+		return err
+	}
+
+	err = Bus.Publish(RedisEventsChannel, string(requestPayload))
+	if err != nil || !RedisPubSubCompatEnabled {
+		return err
+	}
+
+	return Bus.Publish(RedisPubSubChannel, string(requestPayload))
+}
+
+// redisBus sends messages with Redis pub/sub
+type redisBus struct{}
+
+func (redisBus) Name() string {
+	return "redis"
+}
+
+func (redisBus) Available() bool {
+	return isRedisAvailable()
+}
+
+func (redisBus) Publish(channel string, payload string) error {
+	if RedisClient == nil {
+		return fmt.Errorf("Redis is not connected yet")
+	}
+	return RedisClient.Publish(context.TODO(), channel, payload).Err()
+}
+
+func (redisBus) Subscribe(channel string, callback callbackFunc) error {
+	_, err := StartRedisListener(channel, callback)
+	return err
+}
@@ -0,0 +1,149 @@
+package rcsm
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// usedCommandNonces maps the nonces of accepted commands to the time they can be forgotten, it's only used without Redis
+	usedCommandNonces     = make(map[string]time.Time)
+	usedCommandNoncesLock sync.Mutex
+)
+
+// verifyRedisCommand checks the signature, the timestamp and the nonce of a command, maxAge is how old its timestamp can be
+func verifyRedisCommand(command RedisCommand, maxAge time.Duration) error {
+	if command.Signature == "" || command.KeyID == "" || command.Nonce == "" || command.Timestamp == 0 {
+		return fmt.Errorf("command is not signed")
+	}
+
+	key, found := getRedisCommandKey(command.KeyID)
+	if !found {
+		return fmt.Errorf("unknown key %s", command.KeyID)
+	}
+
+	signature, err := hex.DecodeString(command.Signature)
+	if err != nil || !hmac.Equal(signature, signRedisCommand(command, key)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	// The timestamp is signed, unlike the time a command was published or added to a stream
+	maxSkew := time.Duration(RedisCommandMaxAgeSec) * time.Second
+	age := time.Since(time.Unix(command.Timestamp, 0))
+	if age > maxAge || age < -maxSkew {
+		return fmt.Errorf("command expired, its timestamp is %s away from the clock of rcsm", age.Round(time.Second))
+	}
+
+	return useCommandNonce(command)
+}
+
+// getCommandMaxAge returns how old commands received on pub/sub can be
+func getCommandMaxAge() time.Duration {
+	return time.Duration(RedisCommandMaxAgeSec) * time.Second
+}
+
+// getStreamCommandMaxAge returns how old commands read from streams can be, they can wait in the stream while rcsm is down
+func getStreamCommandMaxAge() time.Duration {
+	if RedisCommandsStreamMaxAgeSec > RedisCommandMaxAgeSec {
+		return time.Duration(RedisCommandsStreamMaxAgeSec) * time.Second
+	}
+	return getCommandMaxAge()
+}
+
+// useCommandNonce stores the nonce of a command until it expires, and fails if it was already used
+func useCommandNonce(command RedisCommand) error {
+	// A command is accepted until the longest window expires, whether it's received on pub/sub or on a stream
+	expiration := time.Unix(command.Timestamp, 0).Add(getStreamCommandMaxAge())
+	ttl := time.Until(expiration)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+
+	if !RedisEnabled {
+		return useLocalCommandNonce(command, expiration)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Nonces are kept in Redis so they are remembered after a restart, each instance runs a command once
+	nonceKey := fmt.Sprintf("%s:%s:%s:%s", RedisCommandNoncePrefix, InstanceName, command.KeyID, command.Nonce)
+	stored, err := RedisClient.SetNX(ctx, nonceKey, command.Timestamp, ttl).Result()
+	if err != nil {
+		return fmt.Errorf("could not check the nonce: %s", err)
+	}
+	if !stored {
+		return fmt.Errorf("nonce was already used, command replayed")
+	}
+
+	return nil
+}
+
+// useLocalCommandNonce remembers nonces in memory when Redis is disabled, such as with NATS or MQTT
+func useLocalCommandNonce(command RedisCommand, expiration time.Time) error {
+	usedCommandNoncesLock.Lock()
+	defer usedCommandNoncesLock.Unlock()
+
+	// Nonces can be forgotten once their command would be expired
+	for nonce, nonceExpiration := range usedCommandNonces {
+		if time.Now().After(nonceExpiration) {
+			delete(usedCommandNonces, nonce)
+		}
+	}
+
+	nonceKey := command.KeyID + "\x00" + command.Nonce
+	if _, found := usedCommandNonces[nonceKey]; found {
+		return fmt.Errorf("nonce was already used, command replayed")
+	}
+	usedCommandNonces[nonceKey] = expiration
+
+	return nil
+}
+
+// signRedisCommand returns the HMAC-SHA256 of the signed fields of a command, each of them prefixed with its length
+func signRedisCommand(command RedisCommand, key []byte) []byte {
+	// The instances are a field made of their own length prefixed names, so a name containing a separator can't be split
+	var instances bytes.Buffer
+	for _, instance := range command.Instance {
+		writeSignedField(&instances, instance)
+	}
+
+	var signedFields bytes.Buffer
+	writeSignedField(&signedFields, command.KeyID)
+	writeSignedField(&signedFields, strconv.FormatInt(command.Timestamp, 10))
+	writeSignedField(&signedFields, command.Nonce)
+	writeSignedField(&signedFields, instances.String())
+	writeSignedField(&signedFields, command.Target)
+	writeSignedField(&signedFields, command.Action)
+	writeSignedField(&signedFields, command.Content)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(signedFields.Bytes())
+
+	return mac.Sum(nil)
+}
+
+// writeSignedField writes a field as its length in bytes, a colon and its value, such as "5:test1"
+func writeSignedField(buffer *bytes.Buffer, value string) {
+	buffer.WriteString(strconv.Itoa(len(value)))
+	buffer.WriteByte(':')
+	buffer.WriteString(value)
+}
+
+// getRedisCommandKey returns the secret of a key from RedisCommandKeys
+func getRedisCommandKey(keyID string) ([]byte, bool) {
+	for _, namedKey := range splitConfigList(RedisCommandKeys) {
+		name, secret := parseNamedEndpoint(namedKey, "")
+		if name == keyID && secret != "" {
+			return []byte(secret), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,210 @@
+package rcsm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// setupCommandSignatures configures a signing key and stores nonces in an in-process Redis server
+func setupCommandSignatures(t *testing.T) *miniredis.Miniredis {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Could not start Redis: %s", err)
+	}
+	t.Cleanup(server.Close)
+
+	RedisEnabled = true
+	RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	RedisCommandKeys = "ops=first_secret"
+	RedisCommandMaxAgeSec = 30
+	RedisCommandsStreamMaxAgeSec = 3600
+
+	return server
+}
+
+// newSignedCommand returns a restart command signed with the ops key
+func newSignedCommand(timestamp int64, nonce string) RedisCommand {
+	command := RedisCommand{
+		Target:    "test1",
+		Action:    "restart",
+		KeyID:     "ops",
+		Timestamp: timestamp,
+		Nonce:     nonce,
+	}
+	command.Signature = hex.EncodeToString(signRedisCommand(command, []byte("first_secret")))
+	return command
+}
+
+func TestStreamCommandReplayedWithOldID(t *testing.T) {
+	setupCommandSignatures(t)
+
+	// A command captured two hours ago, added again to the stream with an entry ID matching its timestamp
+	command := newSignedCommand(time.Now().Add(-2*time.Hour).Unix(), "captured")
+
+	err := verifyRedisCommand(command, getStreamCommandMaxAge())
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("Replayed command should be expired, got %v", err)
+	}
+}
+
+func TestStreamCommandReplayedAfterRestart(t *testing.T) {
+	server := setupCommandSignatures(t)
+
+	command := newSignedCommand(time.Now().Add(-10*time.Minute).Unix(), "queued")
+
+	err := verifyRedisCommand(command, getStreamCommandMaxAge())
+	if err != nil {
+		t.Fatalf("Command should be accepted the first time: %s", err)
+	}
+
+	// A restarted rcsm has a new client and nothing in memory
+	RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	usedCommandNonces = make(map[string]time.Time)
+
+	err = verifyRedisCommand(command, getStreamCommandMaxAge())
+	if err == nil || !strings.Contains(err.Error(), "replayed") {
+		t.Fatalf("Command should be rejected as replayed after a restart, got %v", err)
+	}
+}
+
+func TestVerifyRedisCommand(t *testing.T) {
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name string
+		// change modifies the command after it was signed
+		change        func(command *RedisCommand)
+		expectedError string
+	}{
+		{"valid signature", func(command *RedisCommand) {}, ""},
+		{"changed key ID", func(command *RedisCommand) { command.KeyID = "ci" }, "invalid signature"},
+		{"changed timestamp", func(command *RedisCommand) { command.Timestamp-- }, "invalid signature"},
+		{"changed nonce", func(command *RedisCommand) { command.Nonce += "2" }, "invalid signature"},
+		{"changed instances", func(command *RedisCommand) { command.Instance = InstanceSelector{"creative"} }, "invalid signature"},
+		{"changed target", func(command *RedisCommand) { command.Target = "test2" }, "invalid signature"},
+		{"changed action", func(command *RedisCommand) { command.Action = "stop" }, "invalid signature"},
+		{"changed content", func(command *RedisCommand) { command.Content = "op attacker" }, "invalid signature"},
+		{"invalid signature encoding", func(command *RedisCommand) { command.Signature = "not hex" }, "invalid signature"},
+		{"unsigned", func(command *RedisCommand) { command.Signature = "" }, "not signed"},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupCommandSignatures(t)
+			RedisCommandKeys = "ops=first_secret;ci=second_secret"
+
+			command := RedisCommand{
+				Target:    "test1",
+				Action:    "run",
+				Content:   "say hello",
+				Instance:  InstanceSelector{"survival"},
+				KeyID:     "ops",
+				Timestamp: now,
+				Nonce:     fmt.Sprintf("nonce-%d", i),
+			}
+			command.Signature = hex.EncodeToString(signRedisCommand(command, []byte("first_secret")))
+			test.change(&command)
+
+			err := verifyRedisCommand(command, getCommandMaxAge())
+			checkCommandError(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestVerifyRedisCommandUnknownKey(t *testing.T) {
+	setupCommandSignatures(t)
+
+	command := RedisCommand{Target: "test1", Action: "restart", KeyID: "unknown", Timestamp: time.Now().Unix(), Nonce: "unknown-key"}
+	command.Signature = hex.EncodeToString(signRedisCommand(command, []byte("first_secret")))
+
+	err := verifyRedisCommand(command, getCommandMaxAge())
+	checkCommandError(t, err, "unknown key")
+}
+
+func TestVerifyRedisCommandTimestamp(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset        time.Duration
+		expectedError string
+	}{
+		{"recent", -10 * time.Second, ""},
+		{"slightly in the future", 10 * time.Second, ""},
+		{"expired", -time.Minute, "expired"},
+		{"in the future", time.Minute, "expired"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupCommandSignatures(t)
+
+			command := newSignedCommand(time.Now().Add(test.offset).Unix(), "timestamp")
+
+			err := verifyRedisCommand(command, getCommandMaxAge())
+			checkCommandError(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestVerifyRedisCommandReusedNonce(t *testing.T) {
+	setupCommandSignatures(t)
+
+	command := newSignedCommand(time.Now().Unix(), "reused")
+
+	err := verifyRedisCommand(command, getCommandMaxAge())
+	checkCommandError(t, err, "")
+
+	err = verifyRedisCommand(command, getCommandMaxAge())
+	checkCommandError(t, err, "replayed")
+}
+
+func TestVerifyRedisCommandInstanceSeparators(t *testing.T) {
+	tests := []struct {
+		name     string
+		signed   InstanceSelector
+		tampered InstanceSelector
+	}{
+		{"comma", InstanceSelector{"survival,creative"}, InstanceSelector{"survival", "creative"}},
+		{"line break", InstanceSelector{"survival\ncreative"}, InstanceSelector{"survival", "creative"}},
+		{"length prefix", InstanceSelector{"8:survival"}, InstanceSelector{"survival"}},
+		{"merged", InstanceSelector{"survival", "creative"}, InstanceSelector{"survivalcreative"}},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupCommandSignatures(t)
+
+			command := newSignedCommand(time.Now().Unix(), fmt.Sprintf("separators-%d", i))
+			command.Instance = test.signed
+			command.Signature = hex.EncodeToString(signRedisCommand(command, []byte("first_secret")))
+
+			tampered := command
+			tampered.Instance = test.tampered
+			err := verifyRedisCommand(tampered, getCommandMaxAge())
+			checkCommandError(t, err, "invalid signature")
+
+			err = verifyRedisCommand(command, getCommandMaxAge())
+			checkCommandError(t, err, "")
+		})
+	}
+}
+
+// checkCommandError fails the test if err doesn't contain expectedError, or isn't nil when expectedError is empty
+func checkCommandError(t *testing.T, err error, expectedError string) {
+	t.Helper()
+
+	if expectedError == "" {
+		if err != nil {
+			t.Fatalf("Command should be accepted, got: %s", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), expectedError) {
+		t.Fatalf("Expected an error containing %q, got: %v", expectedError, err)
+	}
+}
@@ -0,0 +1,157 @@
+package rcsm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// commandStreamBlock is how long reading a stream waits for new commands
+const commandStreamBlock = 5 * time.Second
+
+// startCommandStreams consumes the shared and the instance command streams, each instance has its own consumer group
+func startCommandStreams() {
+	// New instances don't run the history of the shared stream, but commands queued for them before their first start are run
+	go consumeCommandStream(RedisCommandsStream, InstanceName, "$")
+	go consumeCommandStream(getInstanceStream(), "rcsm", "0")
+}
+
+// getInstanceStream returns the stream for commands sent only to this instance, such as rcsm:command_stream:survival
+func getInstanceStream() string {
+	return RedisCommandsStream + ":" + InstanceName
+}
+
+// getCommandStreamConsumer returns the consumer name of this process, so commands left by a previous host can be claimed
+func getCommandStreamConsumer() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "rcsm"
+	}
+	return InstanceName + "@" + hostname
+}
+
+// consumeCommandStream runs and acknowledges the commands of a stream, starting with the ones interrupted by a crash
+func consumeCommandStream(stream string, group string, groupStart string) {
+	ctx := context.Background()
+	consumer := getCommandStreamConsumer()
+	claimIdle := time.Duration(RedisCommandsStreamClaimIdleSec) * time.Second
+
+	groupCreated := false
+	// Reading from 0 returns the commands delivered to this consumer but not acknowledged
+	lastID := "0"
+	var lastClaim time.Time
+
+	for {
+		if !groupCreated {
+			err := RedisClient.XGroupCreateMkStream(ctx, stream, group, groupStart).Err()
+			if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+				time.Sleep(time.Second)
+				continue
+			}
+			groupCreated = true
+		}
+
+		if time.Since(lastClaim) >= claimIdle {
+			claimIdleCommands(ctx, stream, group, consumer, claimIdle)
+			lastClaim = time.Now()
+		}
+
+		streams, err := RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  []string{stream, lastID},
+			Count:    10,
+			Block:    commandStreamBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			// The group is gone if the stream was deleted
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				groupCreated = false
+			}
+			time.Sleep(time.Second)
+			continue
+		}
+
+		var messages []redis.XMessage
+		for _, xStream := range streams {
+			messages = append(messages, xStream.Messages...)
+		}
+
+		if lastID != ">" && len(messages) == 0 {
+			// No more pending commands, wait for new ones
+			lastID = ">"
+			continue
+		}
+
+		for _, message := range messages {
+			runStreamCommand(ctx, stream, group, message)
+			if lastID != ">" {
+				lastID = message.ID
+			}
+		}
+	}
+}
+
+// claimIdleCommands runs the commands left pending by other consumers of the group for longer than claimIdle
+func claimIdleCommands(ctx context.Context, stream string, group string, consumer string, claimIdle time.Duration) {
+	pendingCommands, err := RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Start:  "-",
+		End:    "+",
+		Count:  100,
+	}).Result()
+	if err != nil {
+		return
+	}
+
+	var idleIDs []string
+	for _, pendingCommand := range pendingCommands {
+		if pendingCommand.Consumer != consumer && pendingCommand.Idle >= claimIdle {
+			idleIDs = append(idleIDs, pendingCommand.ID)
+		}
+	}
+	if len(idleIDs) == 0 {
+		return
+	}
+
+	messages, err := RedisClient.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  claimIdle,
+		Messages: idleIDs,
+	}).Result()
+	if err != nil {
+		TriggerLogEvent("warn", "redis", fmt.Sprintf("Could not claim idle commands of %s: %s", stream, err))
+		return
+	}
+
+	for _, message := range messages {
+		TriggerLogEvent("info", "redis", fmt.Sprintf("Claimed command %s of %s left pending by another consumer", message.ID, stream))
+		runStreamCommand(ctx, stream, group, message)
+	}
+}
+
+// runStreamCommand runs a command with the same dispatch as pub/sub commands, then acknowledges it
+func runStreamCommand(ctx context.Context, stream string, group string, message redis.XMessage) {
+	payload, ok := message.Values["command"].(string)
+	if ok {
+		// Signed commands can wait in the stream while the instance is down, for up to RedisCommandsStreamMaxAgeSec
+		parseRedisCommand(stream, payload, getStreamCommandMaxAge())
+	} else {
+		TriggerLogEvent("warn", "redis", fmt.Sprintf("Ignoring entry %s of %s without a command field", message.ID, stream))
+	}
+
+	// Invalid commands are acknowledged too, they would fail again
+	err := RedisClient.XAck(ctx, stream, group, message.ID).Err()
+	if err != nil {
+		TriggerLogEvent("warn", "redis", fmt.Sprintf("Could not acknowledge command %s of %s: %s", message.ID, stream, err))
+	}
+}
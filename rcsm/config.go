@@ -16,17 +16,145 @@ var (
 
 	// InstanceName is used for event reporting on Redis and Webhooks, useful if you have multiple rcsm instances
 	InstanceName string = "server"
+	// StateFile is where rcsm remembers servers across restarts, such as the version of their template and their last backup
+	StateFile string = "rcsm_state.json"
+
+	// LogFormat is the format of logs, text or json (one JSON object per line)
+	LogFormat string = "text"
+	// LogLevel is the minimum level of logged events, events below it are still sent to Redis and Webhooks
+	LogLevel string = "debug"
+	// LogFile is the file logs are written to instead of stderr, if set
+	LogFile string = ""
+	// LogFileMaxSizeMB is the size of LogFile before it's rotated, 0 disables rotation
+	LogFileMaxSizeMB int64 = 100
+	// LogFileMaxBackups is the number of rotated log files to keep
+	LogFileMaxBackups int64 = 5
+
+	// MessageBusType is the transport of events and commands, redis, nats or mqtt
+	MessageBusType string = "redis"
+
+	// NATSURL is the NATS server to use, multiple servers can be separated by semicolons
+	NATSURL string = "nats://127.0.0.1:4222"
+	// NATSUser is the NATS username, leave empty to use a token or credentials
+	NATSUser string = ""
+	// NATSPassword is the NATS password
+	NATSPassword string = ""
+	// NATSToken is the NATS authentication token
+	NATSToken string = ""
+	// NATSCredentialsFile is the path of a NATS .creds file, for JWT authentication
+	NATSCredentialsFile string = ""
+	// NATSTLSCAFile is the path of the CA certificates used to verify the NATS server
+	NATSTLSCAFile string = ""
+	// NATSTLSCertFile is the path of the client certificate, for mutual TLS
+	NATSTLSCertFile string = ""
+	// NATSTLSKeyFile is the path of the client private key, for mutual TLS
+	NATSTLSKeyFile string = ""
+
+	// MQTTBroker is the MQTT broker to use, such as tcp://127.0.0.1:1883 or ssl://broker:8883, multiple brokers can be separated by semicolons
+	MQTTBroker string = "tcp://127.0.0.1:1883"
+	// MQTTUsername is the MQTT username
+	MQTTUsername string = ""
+	// MQTTPassword is the MQTT password
+	MQTTPassword string = ""
+	// MQTTClientID is the MQTT client ID, it must be unique on the broker, rcsm-<instance name> by default
+	MQTTClientID string = ""
+	// MQTTQoS is the quality of service used to publish and subscribe, 0, 1 or 2
+	MQTTQoS int64 = 1
 
 	// RedisEnabled specifies if Redis communication should be enabled
 	RedisEnabled bool = false
-	// RedisHost specifies the Redis server to use
+	// RedisMode is the kind of Redis deployment, standalone, sentinel or cluster
+	RedisMode string = "standalone"
+	// RedisHost specifies the Redis server to use, or the Sentinel or Cluster nodes separated by semicolons
 	RedisHost string = "localhost:6379"
+	// RedisUsername is the ACL username, leave empty to only use a password
+	RedisUsername string = ""
 	// RedisPassword is the plaintext password of the server
 	RedisPassword string = ""
+	// RedisSentinelMaster is the name of the master monitored by Sentinel
+	RedisSentinelMaster string = "mymaster"
+	// RedisSentinelPassword is the password of the Sentinel nodes, if different from the Redis one
+	RedisSentinelPassword string = ""
+	// RedisTLSEnabled specifies if the connection to Redis uses TLS
+	RedisTLSEnabled bool = false
+	// RedisTLSCAFile is the PEM file of the CA used to verify Redis, the system CAs are used if empty
+	RedisTLSCAFile string = ""
+	// RedisTLSCertFile is the PEM certificate used to authenticate with Redis, if required
+	RedisTLSCertFile string = ""
+	// RedisTLSKeyFile is the PEM key of RedisTLSCertFile
+	RedisTLSKeyFile string = ""
+	// RedisTLSServerName is the server name used for SNI and to verify the certificate, the host is used if empty
+	RedisTLSServerName string = ""
+	// RedisTLSSkipVerify disables the verification of the Redis certificate, for testing only
+	RedisTLSSkipVerify bool = false
 	// RedisDatabase is the database ID used for Redis
 	RedisDatabase int64 = 0
-	// RedisPubSubChannel is the channel used for Redis pub/sub notifications
+	// RedisEventsChannel is the channel events are published on
+	RedisEventsChannel string = "rcsm:events"
+	// RedisCommandsChannel is the channel commands are received on, each instance also listens on <channel>:<instance>
+	RedisCommandsChannel string = "rcsm:commands"
+	// RedisCommandsStreamEnabled specifies if commands should also be read from Redis Streams, so they are not lost while rcsm is down
+	RedisCommandsStreamEnabled bool = false
+	// RedisCommandsStream is the stream commands are read from, each instance also reads <stream>:<instance>
+	RedisCommandsStream string = "rcsm:command_stream"
+	// RedisCommandsStreamClaimIdleSec is how long a command can stay pending on another consumer before it's claimed
+	RedisCommandsStreamClaimIdleSec int64 = 300
+	// RedisCommandsStreamMaxAgeSec is how old signed commands read from streams can be, RedisCommandMaxAgeSec is used if it's lower
+	RedisCommandsStreamMaxAgeSec int64 = 0
+	// RedisPubSubCompatEnabled specifies if events and commands should also use RedisPubSubChannel, like older versions
+	RedisPubSubCompatEnabled bool = true
+	// RedisPubSubChannel is the channel used for both events and commands in compatibility mode
 	RedisPubSubChannel string = "rcsm"
+	// RedisCommandSignaturesEnabled specifies if Redis commands must be signed, unsigned commands are rejected
+	RedisCommandSignaturesEnabled bool = false
+	// RedisCommandKeys is the list of keys commands can be signed with, such as "ops=secret1;ci=secret2"
+	RedisCommandKeys string = ""
+	// RedisCommandMaxAgeSec is how old (or how far in the future) the timestamp of a signed command can be
+	RedisCommandMaxAgeSec int64 = 30
+	// RedisCommandNoncePrefix is the prefix of the keys remembering the nonces of signed commands
+	RedisCommandNoncePrefix string = "rcsm:command_nonce"
+	// RedisRegistryEnabled specifies if the state of this instance and its servers should be published in Redis hashes
+	RedisRegistryEnabled bool = false
+	// RedisRegistryPrefix is the prefix of the registry keys
+	RedisRegistryPrefix string = "rcsm:registry"
+	// RedisRegistryIntervalSec is the delay between two heartbeats of the registry
+	RedisRegistryIntervalSec int64 = 10
+	// RedisRegistryTTLSec is how long registry hashes are kept without heartbeat
+	RedisRegistryTTLSec int64 = 30
+	// RedisRegistryPruneAfterSec is how long instances without heartbeat are kept in the list of instances, 0 keeps them forever
+	RedisRegistryPruneAfterSec int64 = 604800
+
+	// RedisLeaderElectionEnabled specifies if a single instance should be elected with a Redis lease to run global jobs
+	RedisLeaderElectionEnabled bool = false
+	// RedisLeaderKey is the key of the leader lease
+	RedisLeaderKey string = "rcsm:leader"
+	// RedisLeaderLeaseTTLSec is how long the lease is kept without renewal, another instance takes over once it expired
+	RedisLeaderLeaseTTLSec int64 = 15
+	// RedisLeaderRenewIntervalSec is the delay between two renewals of the lease, it must be lower than the TTL
+	RedisLeaderRenewIntervalSec int64 = 5
+	// RedisHealthCheckIntervalSec is the delay between two pings to Redis while it's available
+	RedisHealthCheckIntervalSec int64 = 5
+	// RedisReconnectMaxDelaySec is the maximum delay between two connection attempts while Redis is unavailable
+	RedisReconnectMaxDelaySec int64 = 60
+
+	// TemplatesEnabled specifies if servers should be updated from templates, it defaults to S3Enabled
+	TemplatesEnabled bool = false
+	// TemplateSourceType specifies where templates are downloaded from, it can be s3, local or http
+	TemplateSourceType string = "s3"
+	// TemplateLocalPath is the directory containing templates, or a single template archive, for the local source
+	TemplateLocalPath string = ""
+	// TemplateHTTPURL is the base URL where templates are downloaded from for the http source
+	TemplateHTTPURL string = ""
+	// TemplateHTTPTimeoutSec is how long checking or downloading a template from the http source can take
+	TemplateHTTPTimeoutSec int64 = 300
+	// TemplatePrefix is prepended to template keys, such as "prod/" or "staging/"
+	TemplatePrefix string = ""
+	// TemplateVersion pins the template version of every server, unless set in rcsm_config.json
+	TemplateVersion string = ""
+	// TemplateSignatureEnabled specifies if templates must have a valid detached signature to be applied
+	TemplateSignatureEnabled bool = false
+	// TemplateTrustedKeys is the list of minisign or base64 ed25519 public keys trusted to sign templates, separated by semicolons
+	TemplateTrustedKeys string = ""
 
 	// S3Enabled specifies wether or not S3 is enabled to update the server from templates
 	S3Enabled bool = false
@@ -41,6 +169,15 @@ var (
 	// AWSSecretAccessKey is the secret key for S3 authentication
 	AWSSecretAccessKey string = ""
 
+	// TemplateVariablesEnabled specifies if variables should be substituted in template files when they are extracted
+	TemplateVariablesEnabled bool = false
+	// TemplateVariablesFiles is the list of file patterns in which variables are substituted, separated by semicolons, files ending with .tmpl always are
+	TemplateVariablesFiles string = "server.properties"
+	// TemplateVariablesStrict specifies if an undefined variable should prevent the template from being applied
+	TemplateVariablesStrict bool = false
+	// TemplateVariables is a list of custom variables available to every server, such as "KEY=value;OTHER_KEY=value"
+	TemplateVariables string = ""
+
 	// S3BackupEnabled specifies wether or not S3 is enabled to backup the files
 	S3BackupEnabled bool = false
 	// S3BackupEndpoint specifies the S3 endpoint if you use something else than AWS
@@ -72,10 +209,130 @@ var (
 	// AutoRestartCrashTimeoutSec specifies for how long rcsm will wait to kill the server if not responding
 	AutoRestartCrashTimeoutSec int64 = 60
 
+	// EventsQueueSize is the number of events each sink (Webhooks, Redis) can queue before applying EventsQueuePolicy
+	EventsQueueSize int64 = 1000
+	// EventsQueuePolicy specifies what to do when a queue is full, it can be drop-newest, drop-oldest or block
+	EventsQueuePolicy string = "drop-newest"
+	// EventsFlushTimeoutSec specifies for how long rcsm will wait for queued events to be sent when it stops
+	EventsFlushTimeoutSec int64 = 10
+
+	// EventSinkLevels sets the minimum level of events for sinks, such as "discord=warn;discord:oncall=severe;redis=info"
+	EventSinkLevels string = ""
+	// EventRoutesFile is the path of an optional JSON file with rules routing events to specific sinks
+	EventRoutesFile string = ""
+
+	// EventsDedupWindowSec specifies for how long identical events are collapsed into one webhook message with a count, 0 disables it
+	EventsDedupWindowSec int64 = 60
+	// EventsDigestEnabled specifies if low priority events should be batched in periodic webhook digests
+	EventsDigestEnabled bool = false
+	// EventsDigestMaxLevel is the highest level of events batched in digests
+	EventsDigestMaxLevel string = "info"
+	// EventsDigestIntervalSec specifies how often digests are sent
+	EventsDigestIntervalSec int64 = 300
+	// EventsResolvedEnabled specifies if a resolved event should be sent when a problem such as a crash is cleared
+	EventsResolvedEnabled bool = true
+
+	// EventsOutboxEnabled specifies if events that could not be delivered should be stored on disk and retried
+	EventsOutboxEnabled bool = false
+	// EventsOutboxDirectory is the directory where undelivered events are stored, with a subdirectory for each sink
+	EventsOutboxDirectory string = "rcsm_outbox"
+	// EventsOutboxMaxEvents is the number of events stored for each sink before dropping the oldest ones, 0 means unlimited
+	EventsOutboxMaxEvents int64 = 10000
+	// EventsOutboxMaxRetryDelaySec is the maximum delay between two delivery attempts of stored events
+	EventsOutboxMaxRetryDelaySec int64 = 300
+	// EventsOutboxMaxAttempts is the number of failed deliveries before an event is moved to the failed events, 0 means unlimited
+	EventsOutboxMaxAttempts int64 = 100
+
+	// SyslogEnabled specifies if events should be sent to syslog, using the RFC 5424 format
+	SyslogEnabled bool = false
+	// SyslogNetwork is the network used to reach syslog, unixgram, unix, udp or tcp
+	SyslogNetwork string = "unixgram"
+	// SyslogAddress is the socket path or address of syslog
+	SyslogAddress string = "/dev/log"
+	// SyslogFacility is the facility of events sent to syslog, such as daemon or local0
+	SyslogFacility string = "daemon"
+	// SyslogAppName is the app name of events sent to syslog and journald
+	SyslogAppName string = "rcsm"
+
+	// JournaldEnabled specifies if events should be sent to the systemd journal with structured fields
+	JournaldEnabled bool = false
+	// JournaldSocket is the path of the journald native protocol socket
+	JournaldSocket string = "/run/systemd/journal/socket"
+
+	// ConsoleParsingEnabled specifies if the console of servers should be captured and parsed into events
+	ConsoleParsingEnabled bool = false
+	// ConsoleCaptureDirectory is the directory where the console of each server is captured
+	ConsoleCaptureDirectory string = "rcsm_console"
+	// ConsoleCaptureMaxSizeMB is the size of a console capture before it's emptied, once it has been parsed, 0 disables it
+	ConsoleCaptureMaxSizeMB int64 = 10
+	// ConsoleDefaultRulesEnabled specifies if the rules for Paper, Spigot, Velocity and BungeeCord should be used
+	ConsoleDefaultRulesEnabled bool = true
+	// ConsoleRulesFile is an optional JSON file with custom console rules
+	ConsoleRulesFile string = ""
+
+	// ProxyRegistrationEnabled specifies if servers should be registered on proxies with Redis messages when they start and stop
+	ProxyRegistrationEnabled bool = false
+	// ProxyRegistrationChannel is the Redis channel of proxy messages
+	ProxyRegistrationChannel string = "rcsm:proxy"
+	// ProxyAdvertisedHost is the host proxies use to connect to servers, the hostname by default or 127.0.0.1 for a local proxy
+	ProxyAdvertisedHost string = ""
+	// ProxyRegisterOnReady specifies if servers should be registered once their console says they are ready, it requires console parsing
+	ProxyRegisterOnReady bool = false
+	// ProxyConfigType is the type of the local proxy config to update, velocity or bungeecord, empty to disable it
+	ProxyConfigType string = ""
+	// ProxyConfigFile is the path of the local proxy config, such as velocity.toml or config.yml
+	ProxyConfigFile string = ""
+	// ProxyReloadServer is the name of the proxy server managed by rcsm, it's reloaded after its config was updated
+	ProxyReloadServer string = ""
+	// ProxyReloadCommand is the console command reloading the proxy config, such as velocity reload or greload
+	ProxyReloadCommand string = ""
+
 	// WebhooksEnabled specifies if Webhooks (using Discord format) are enabled for alerts
 	WebhooksEnabled bool = false
-	// WebhooksEndpoint is the endpoint to use to send notifications to
+	// WebhooksEndpoint is the endpoint to use to send notifications to, multiple endpoints can be separated by semicolons
 	WebhooksEndpoint string = ""
+	// WebhooksMentionRoles is the list of Discord role IDs to ping for important events, separated by semicolons
+	WebhooksMentionRoles string = ""
+	// WebhooksMentionUsers is the list of Discord user IDs to ping for important events, separated by semicolons
+	WebhooksMentionUsers string = ""
+	// WebhooksMentionMinLevel is the minimum level of events pinging WebhooksMentionRoles and WebhooksMentionUsers
+	WebhooksMentionMinLevel string = "severe"
+	// WebhooksStatusEnabled specifies if a Discord message listing servers and their state should be kept up to date
+	WebhooksStatusEnabled bool = false
+	// WebhooksStatusEndpoints is the list of Discord webhooks the status is sent to, every Discord webhook if empty
+	WebhooksStatusEndpoints string = ""
+	// WebhooksStatusIntervalSec is the delay between two updates of the status message
+	WebhooksStatusIntervalSec int64 = 60
+	// WebhooksStatusMessagesFile is where the IDs of the status messages are kept, so they are edited again after a restart
+	WebhooksStatusMessagesFile string = "rcsm_discord_status.json"
+
+	// SlackEnabled specifies if Slack incoming webhooks are enabled for alerts
+	SlackEnabled bool = false
+	// SlackWebhooks is the list of Slack incoming webhook URLs, separated by semicolons
+	SlackWebhooks string = ""
+
+	// MatrixEnabled specifies if Matrix rooms are enabled for alerts
+	MatrixEnabled bool = false
+	// MatrixHomeserver is the URL of the Matrix homeserver, such as https://matrix.org
+	MatrixHomeserver string = ""
+	// MatrixAccessToken is the access token of the Matrix user sending alerts
+	MatrixAccessToken string = ""
+	// MatrixRooms is the list of Matrix room IDs to send alerts to, separated by semicolons
+	MatrixRooms string = ""
+
+	// TelegramEnabled specifies if Telegram chats are enabled for alerts
+	TelegramEnabled bool = false
+	// TelegramBotToken is the token of the Telegram bot sending alerts
+	TelegramBotToken string = ""
+	// TelegramChatIDs is the list of Telegram chat IDs to send alerts to, separated by semicolons
+	TelegramChatIDs string = ""
+
+	// JSONWebhooksEnabled specifies if generic JSON webhooks are enabled for alerts
+	JSONWebhooksEnabled bool = false
+	// JSONWebhooksEndpoints is the list of generic webhook URLs, separated by semicolons
+	JSONWebhooksEndpoints string = ""
+	// JSONWebhooksTemplate is an optional Go template used as the request body instead of the Redis message format
+	JSONWebhooksTemplate string = ""
 
 	// AutoUpdateEnabled specifies if the auto update system should check for new versions of rcsm and install them
 	AutoUpdateEnabled bool = true
@@ -94,12 +351,69 @@ func ReadConfig() {
 	godotenv.Load(EnvFile)
 
 	InstanceName = ReadEnvString("INSTANCE_NAME", InstanceName)
+	StateFile = ReadEnvString("STATE_FILE", StateFile)
+
+	LogFormat = ReadEnvString("LOG_FORMAT", LogFormat)
+	LogLevel = ReadEnvString("LOG_LEVEL", LogLevel)
+	LogFile = ReadEnvString("LOG_FILE", LogFile)
+	LogFileMaxSizeMB = ReadEnvInt("LOG_FILE_MAX_SIZE_MB", LogFileMaxSizeMB)
+	LogFileMaxBackups = ReadEnvInt("LOG_FILE_MAX_BACKUPS", LogFileMaxBackups)
+
+	MessageBusType = ReadEnvString("MESSAGE_BUS", MessageBusType)
+
+	NATSURL = ReadEnvString("NATS_URL", NATSURL)
+	NATSUser = ReadEnvString("NATS_USER", NATSUser)
+	NATSPassword = ReadEnvString("NATS_PASSWORD", NATSPassword)
+	NATSToken = ReadEnvString("NATS_TOKEN", NATSToken)
+	NATSCredentialsFile = ReadEnvString("NATS_CREDENTIALS_FILE", NATSCredentialsFile)
+	NATSTLSCAFile = ReadEnvString("NATS_TLS_CA_FILE", NATSTLSCAFile)
+	NATSTLSCertFile = ReadEnvString("NATS_TLS_CERT_FILE", NATSTLSCertFile)
+	NATSTLSKeyFile = ReadEnvString("NATS_TLS_KEY_FILE", NATSTLSKeyFile)
+
+	MQTTBroker = ReadEnvString("MQTT_BROKER", MQTTBroker)
+	MQTTUsername = ReadEnvString("MQTT_USERNAME", MQTTUsername)
+	MQTTPassword = ReadEnvString("MQTT_PASSWORD", MQTTPassword)
+	MQTTClientID = ReadEnvString("MQTT_CLIENT_ID", MQTTClientID)
+	MQTTQoS = ReadEnvInt("MQTT_QOS", MQTTQoS)
 
 	RedisEnabled = ReadEnvBool("REDIS_ENABLED", RedisEnabled)
+	RedisMode = ReadEnvString("REDIS_MODE", RedisMode)
 	RedisHost = ReadEnvString("REDIS_HOST", RedisHost)
+	RedisUsername = ReadEnvString("REDIS_USERNAME", RedisUsername)
 	RedisPassword = ReadEnvString("REDIS_PASSWORD", RedisPassword)
+	RedisSentinelMaster = ReadEnvString("REDIS_SENTINEL_MASTER", RedisSentinelMaster)
+	RedisSentinelPassword = ReadEnvString("REDIS_SENTINEL_PASSWORD", RedisSentinelPassword)
+	RedisTLSEnabled = ReadEnvBool("REDIS_TLS_ENABLED", RedisTLSEnabled)
+	RedisTLSCAFile = ReadEnvString("REDIS_TLS_CA_FILE", RedisTLSCAFile)
+	RedisTLSCertFile = ReadEnvString("REDIS_TLS_CERT_FILE", RedisTLSCertFile)
+	RedisTLSKeyFile = ReadEnvString("REDIS_TLS_KEY_FILE", RedisTLSKeyFile)
+	RedisTLSServerName = ReadEnvString("REDIS_TLS_SERVER_NAME", RedisTLSServerName)
+	RedisTLSSkipVerify = ReadEnvBool("REDIS_TLS_SKIP_VERIFY", RedisTLSSkipVerify)
 	RedisDatabase = ReadEnvInt("REDIS_DATABASE", RedisDatabase)
+	RedisEventsChannel = ReadEnvString("REDIS_EVENTS_CHANNEL", RedisEventsChannel)
+	RedisCommandsChannel = ReadEnvString("REDIS_COMMANDS_CHANNEL", RedisCommandsChannel)
+	RedisCommandsStreamEnabled = ReadEnvBool("REDIS_COMMANDS_STREAM_ENABLED", RedisCommandsStreamEnabled)
+	RedisCommandsStream = ReadEnvString("REDIS_COMMANDS_STREAM", RedisCommandsStream)
+	RedisCommandsStreamClaimIdleSec = ReadEnvInt("REDIS_COMMANDS_STREAM_CLAIM_IDLE_SEC", RedisCommandsStreamClaimIdleSec)
+	RedisCommandsStreamMaxAgeSec = ReadEnvInt("REDIS_COMMANDS_STREAM_MAX_AGE_SEC", RedisCommandsStreamMaxAgeSec)
+	RedisPubSubCompatEnabled = ReadEnvBool("REDIS_PUB_SUB_COMPAT_ENABLED", RedisPubSubCompatEnabled)
 	RedisPubSubChannel = ReadEnvString("REDIS_PUB_SUB_CHANNEL", RedisPubSubChannel)
+	RedisCommandSignaturesEnabled = ReadEnvBool("REDIS_COMMAND_SIGNATURES_ENABLED", RedisCommandSignaturesEnabled)
+	RedisCommandKeys = ReadEnvString("REDIS_COMMAND_KEYS", RedisCommandKeys)
+	RedisCommandMaxAgeSec = ReadEnvInt("REDIS_COMMAND_MAX_AGE_SEC", RedisCommandMaxAgeSec)
+	RedisCommandNoncePrefix = ReadEnvString("REDIS_COMMAND_NONCE_PREFIX", RedisCommandNoncePrefix)
+	RedisRegistryEnabled = ReadEnvBool("REDIS_REGISTRY_ENABLED", RedisRegistryEnabled)
+	RedisRegistryPrefix = ReadEnvString("REDIS_REGISTRY_PREFIX", RedisRegistryPrefix)
+	RedisRegistryIntervalSec = ReadEnvInt("REDIS_REGISTRY_INTERVAL_SEC", RedisRegistryIntervalSec)
+	RedisRegistryTTLSec = ReadEnvInt("REDIS_REGISTRY_TTL_SEC", RedisRegistryTTLSec)
+	RedisRegistryPruneAfterSec = ReadEnvInt("REDIS_REGISTRY_PRUNE_AFTER_SEC", RedisRegistryPruneAfterSec)
+
+	RedisLeaderElectionEnabled = ReadEnvBool("REDIS_LEADER_ELECTION_ENABLED", RedisLeaderElectionEnabled)
+	RedisLeaderKey = ReadEnvString("REDIS_LEADER_KEY", RedisLeaderKey)
+	RedisLeaderLeaseTTLSec = ReadEnvInt("REDIS_LEADER_LEASE_TTL_SEC", RedisLeaderLeaseTTLSec)
+	RedisLeaderRenewIntervalSec = ReadEnvInt("REDIS_LEADER_RENEW_INTERVAL_SEC", RedisLeaderRenewIntervalSec)
+	RedisHealthCheckIntervalSec = ReadEnvInt("REDIS_HEALTH_CHECK_INTERVAL_SEC", RedisHealthCheckIntervalSec)
+	RedisReconnectMaxDelaySec = ReadEnvInt("REDIS_RECONNECT_MAX_DELAY_SEC", RedisReconnectMaxDelaySec)
 
 	S3Enabled = ReadEnvBool("S3_ENABLED", S3Enabled)
 	S3Endpoint = ReadEnvString("S3_ENDPOINT", S3Endpoint)
@@ -108,6 +422,21 @@ func ReadConfig() {
 	AWSAccessKeyID = ReadEnvString("AWS_ACCESS_KEY_ID", AWSAccessKeyID)
 	AWSSecretAccessKey = ReadEnvString("AWS_SECRET_ACCESS_KEY", AWSSecretAccessKey)
 
+	TemplatesEnabled = ReadEnvBool("TEMPLATES_ENABLED", S3Enabled)
+	TemplateSourceType = ReadEnvString("TEMPLATE_SOURCE", TemplateSourceType)
+	TemplateLocalPath = ReadEnvString("TEMPLATE_LOCAL_PATH", TemplateLocalPath)
+	TemplateHTTPURL = ReadEnvString("TEMPLATE_HTTP_URL", TemplateHTTPURL)
+	TemplateHTTPTimeoutSec = ReadEnvInt("TEMPLATE_HTTP_TIMEOUT_SEC", TemplateHTTPTimeoutSec)
+	TemplatePrefix = ReadEnvString("TEMPLATE_PREFIX", TemplatePrefix)
+	TemplateVersion = ReadEnvString("TEMPLATE_VERSION", TemplateVersion)
+	TemplateSignatureEnabled = ReadEnvBool("TEMPLATE_SIGNATURE_ENABLED", TemplateSignatureEnabled)
+	TemplateTrustedKeys = ReadEnvString("TEMPLATE_TRUSTED_KEYS", TemplateTrustedKeys)
+
+	TemplateVariablesEnabled = ReadEnvBool("TEMPLATE_VARIABLES_ENABLED", TemplateVariablesEnabled)
+	TemplateVariablesFiles = ReadEnvString("TEMPLATE_VARIABLES_FILES", TemplateVariablesFiles)
+	TemplateVariablesStrict = ReadEnvBool("TEMPLATE_VARIABLES_STRICT", TemplateVariablesStrict)
+	TemplateVariables = ReadEnvString("TEMPLATE_VARIABLES", TemplateVariables)
+
 	S3BackupEnabled = ReadEnvBool("S3_BACKUP_ENABLED", S3BackupEnabled)
 	S3BackupEndpoint = ReadEnvString("S3_BACKUP_ENDPOINT", S3BackupEndpoint)
 	S3BackupRegion = ReadEnvString("S3_BACKUP_REGION", S3BackupRegion)
@@ -125,8 +454,74 @@ func ReadConfig() {
 	AutoRestartCrashMaxTries = ReadEnvInt("AUTO_RESTART_CRASH_MAX_TRIES", AutoRestartCrashMaxTries)
 	AutoRestartCrashTimeoutSec = ReadEnvInt("AUTO_RESTART_CRASH_TIMEOUT_SEC", AutoRestartCrashTimeoutSec)
 
+	EventsQueueSize = ReadEnvInt("EVENTS_QUEUE_SIZE", EventsQueueSize)
+	EventsQueuePolicy = ReadEnvString("EVENTS_QUEUE_POLICY", EventsQueuePolicy)
+	EventsFlushTimeoutSec = ReadEnvInt("EVENTS_FLUSH_TIMEOUT_SEC", EventsFlushTimeoutSec)
+
+	EventSinkLevels = ReadEnvString("EVENT_SINK_LEVELS", EventSinkLevels)
+	EventRoutesFile = ReadEnvString("EVENT_ROUTES_FILE", EventRoutesFile)
+
+	EventsDedupWindowSec = ReadEnvInt("EVENTS_DEDUP_WINDOW_SEC", EventsDedupWindowSec)
+	EventsDigestEnabled = ReadEnvBool("EVENTS_DIGEST_ENABLED", EventsDigestEnabled)
+	EventsDigestMaxLevel = ReadEnvString("EVENTS_DIGEST_MAX_LEVEL", EventsDigestMaxLevel)
+	EventsDigestIntervalSec = ReadEnvInt("EVENTS_DIGEST_INTERVAL_SEC", EventsDigestIntervalSec)
+	EventsResolvedEnabled = ReadEnvBool("EVENTS_RESOLVED_ENABLED", EventsResolvedEnabled)
+
+	EventsOutboxEnabled = ReadEnvBool("EVENTS_OUTBOX_ENABLED", EventsOutboxEnabled)
+	EventsOutboxDirectory = ReadEnvString("EVENTS_OUTBOX_DIRECTORY", EventsOutboxDirectory)
+	EventsOutboxMaxEvents = ReadEnvInt("EVENTS_OUTBOX_MAX_EVENTS", EventsOutboxMaxEvents)
+	EventsOutboxMaxRetryDelaySec = ReadEnvInt("EVENTS_OUTBOX_MAX_RETRY_DELAY_SEC", EventsOutboxMaxRetryDelaySec)
+	EventsOutboxMaxAttempts = ReadEnvInt("EVENTS_OUTBOX_MAX_ATTEMPTS", EventsOutboxMaxAttempts)
+
+	SyslogEnabled = ReadEnvBool("SYSLOG_ENABLED", SyslogEnabled)
+	SyslogNetwork = ReadEnvString("SYSLOG_NETWORK", SyslogNetwork)
+	SyslogAddress = ReadEnvString("SYSLOG_ADDRESS", SyslogAddress)
+	SyslogFacility = ReadEnvString("SYSLOG_FACILITY", SyslogFacility)
+	SyslogAppName = ReadEnvString("SYSLOG_APP_NAME", SyslogAppName)
+
+	JournaldEnabled = ReadEnvBool("JOURNALD_ENABLED", JournaldEnabled)
+	JournaldSocket = ReadEnvString("JOURNALD_SOCKET", JournaldSocket)
+
+	ConsoleParsingEnabled = ReadEnvBool("CONSOLE_PARSING_ENABLED", ConsoleParsingEnabled)
+	ConsoleCaptureDirectory = ReadEnvString("CONSOLE_CAPTURE_DIRECTORY", ConsoleCaptureDirectory)
+	ConsoleCaptureMaxSizeMB = ReadEnvInt("CONSOLE_CAPTURE_MAX_SIZE_MB", ConsoleCaptureMaxSizeMB)
+	ConsoleDefaultRulesEnabled = ReadEnvBool("CONSOLE_DEFAULT_RULES_ENABLED", ConsoleDefaultRulesEnabled)
+	ConsoleRulesFile = ReadEnvString("CONSOLE_RULES_FILE", ConsoleRulesFile)
+
+	ProxyRegistrationEnabled = ReadEnvBool("PROXY_REGISTRATION_ENABLED", ProxyRegistrationEnabled)
+	ProxyRegistrationChannel = ReadEnvString("PROXY_REGISTRATION_CHANNEL", ProxyRegistrationChannel)
+	ProxyAdvertisedHost = ReadEnvString("PROXY_ADVERTISED_HOST", ProxyAdvertisedHost)
+	ProxyRegisterOnReady = ReadEnvBool("PROXY_REGISTER_ON_READY", ProxyRegisterOnReady)
+	ProxyConfigType = ReadEnvString("PROXY_CONFIG_TYPE", ProxyConfigType)
+	ProxyConfigFile = ReadEnvString("PROXY_CONFIG_FILE", ProxyConfigFile)
+	ProxyReloadServer = ReadEnvString("PROXY_RELOAD_SERVER", ProxyReloadServer)
+	ProxyReloadCommand = ReadEnvString("PROXY_RELOAD_COMMAND", ProxyReloadCommand)
+
 	WebhooksEnabled = ReadEnvBool("WEBHOOKS_ENABLED", WebhooksEnabled)
 	WebhooksEndpoint = ReadEnvString("WEBHOOKS_ENDPOINT", WebhooksEndpoint)
+	WebhooksMentionRoles = ReadEnvString("WEBHOOKS_MENTION_ROLES", WebhooksMentionRoles)
+	WebhooksMentionUsers = ReadEnvString("WEBHOOKS_MENTION_USERS", WebhooksMentionUsers)
+	WebhooksMentionMinLevel = ReadEnvString("WEBHOOKS_MENTION_MIN_LEVEL", WebhooksMentionMinLevel)
+	WebhooksStatusEnabled = ReadEnvBool("WEBHOOKS_STATUS_ENABLED", WebhooksStatusEnabled)
+	WebhooksStatusEndpoints = ReadEnvString("WEBHOOKS_STATUS_ENDPOINTS", WebhooksStatusEndpoints)
+	WebhooksStatusIntervalSec = ReadEnvInt("WEBHOOKS_STATUS_INTERVAL_SEC", WebhooksStatusIntervalSec)
+	WebhooksStatusMessagesFile = ReadEnvString("WEBHOOKS_STATUS_MESSAGES_FILE", WebhooksStatusMessagesFile)
+
+	SlackEnabled = ReadEnvBool("SLACK_ENABLED", SlackEnabled)
+	SlackWebhooks = ReadEnvString("SLACK_WEBHOOKS", SlackWebhooks)
+
+	MatrixEnabled = ReadEnvBool("MATRIX_ENABLED", MatrixEnabled)
+	MatrixHomeserver = ReadEnvString("MATRIX_HOMESERVER", MatrixHomeserver)
+	MatrixAccessToken = ReadEnvString("MATRIX_ACCESS_TOKEN", MatrixAccessToken)
+	MatrixRooms = ReadEnvString("MATRIX_ROOMS", MatrixRooms)
+
+	TelegramEnabled = ReadEnvBool("TELEGRAM_ENABLED", TelegramEnabled)
+	TelegramBotToken = ReadEnvString("TELEGRAM_BOT_TOKEN", TelegramBotToken)
+	TelegramChatIDs = ReadEnvString("TELEGRAM_CHAT_IDS", TelegramChatIDs)
+
+	JSONWebhooksEnabled = ReadEnvBool("JSON_WEBHOOKS_ENABLED", JSONWebhooksEnabled)
+	JSONWebhooksEndpoints = ReadEnvString("JSON_WEBHOOKS_ENDPOINTS", JSONWebhooksEndpoints)
+	JSONWebhooksTemplate = ReadEnvString("JSON_WEBHOOKS_TEMPLATE", JSONWebhooksTemplate)
 
 	AutoUpdateEnabled = ReadEnvBool("AUTO_UPDATE_ENABLED", AutoUpdateEnabled)
 	AutoUpdateIntervalMinutes = ReadEnvInt("AUTO_UPDATE_INTERVAL_MINUTES", AutoUpdateIntervalMinutes)
@@ -174,3 +569,15 @@ func ReadEnvBool(envName string, defaultValue bool) bool {
 	}
 	return false
 }
+
+// splitConfigList splits a semicolon separated config value and ignores empty items
+func splitConfigList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
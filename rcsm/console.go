@@ -0,0 +1,287 @@
+package rcsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStackTraceLines is the number of stack trace lines kept in exception events
+const maxStackTraceLines = 50
+
+var (
+	// ansiEscapeRegex matches terminal colors and cursor movements in the console output
+	ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]|\x1b\][^\x07]*\x07`)
+	// stackTraceLineRegex matches the lines following an exception, with an optional log prefix
+	stackTraceLineRegex = regexp.MustCompile(`^(?:\[[^\]]*\]:?\s*)*(?:\s*at |\s*Caused by: |\s*\.\.\. \d+ more|\s+Suppressed: )`)
+
+	consoleRules     []*ConsoleRule
+	consoleRulesOnce sync.Once
+
+	consoleWatchers     = make(map[string]bool)
+	consoleWatchersLock sync.Mutex
+)
+
+// ConsoleRule defines a regex matching console lines and the event triggered for them
+// Named groups of the pattern, such as (?P<player>\w+), are added to the fields of the event
+type ConsoleRule struct {
+	Pattern string    `json:"pattern"`
+	Type    EventType `json:"type"`
+	Level   string    `json:"level"`
+	// Message is the message of the event, groups can be used such as "${player} joined", the line is used if empty
+	Message string `json:"message"`
+	// StackTrace adds the following stack trace lines to the event
+	StackTrace bool `json:"stack_trace"`
+	regex      *regexp.Regexp
+	// registersProxy is only set for default ready rules, custom rules could match lines written by players
+	registersProxy bool
+}
+
+// consoleLogPrefix matches the prefix of Paper, Spigot and Velocity log lines, such as "[12:34:56 INFO]: " or "[12:34:56] [Server thread/INFO]: "
+// Chat lines continue with "<player>", so what players type can't match the text following it
+func consoleLogPrefix(level string) string {
+	return `^(?:\[\d{2}:\d{2}:\d{2} ` + level + `\]|\[\d{2}:\d{2}:\d{2}\] \[[^\]]+/` + level + `\]): `
+}
+
+// bungeeLogPrefix matches the prefix of BungeeCord log lines, such as "12:34:56 [INFO] "
+func bungeeLogPrefix(level string) string {
+	return `^\d{2}:\d{2}:\d{2} \[` + level + `\] `
+}
+
+// defaultConsoleRules are rules for Paper, Spigot, Velocity and BungeeCord, anchored to the log prefix so they can't be matched by chat
+var defaultConsoleRules = []*ConsoleRule{
+	// Paper and Spigot
+	{Pattern: consoleLogPrefix("INFO") + `(?P<player>[A-Za-z0-9_]{1,16}) joined the game$`, Type: EventPlayerJoin, Level: "debug", Message: "${player} joined the game"},
+	{Pattern: consoleLogPrefix("INFO") + `(?P<player>[A-Za-z0-9_]{1,16}) left the game$`, Type: EventPlayerLeave, Level: "debug", Message: "${player} left the game"},
+	// Velocity
+	{Pattern: consoleLogPrefix("INFO") + `\[connected player\] (?P<player>[A-Za-z0-9_]{1,16}) \([^)]*\) has connected$`, Type: EventPlayerJoin, Level: "debug", Message: "${player} joined the network"},
+	{Pattern: consoleLogPrefix("INFO") + `\[connected player\] (?P<player>[A-Za-z0-9_]{1,16}) \([^)]*\) has disconnected$`, Type: EventPlayerLeave, Level: "debug", Message: "${player} left the network"},
+	// BungeeCord
+	{Pattern: bungeeLogPrefix("INFO") + `\[(?:/[^|\]]+\|)?(?P<player>[A-Za-z0-9_]{1,16})\] <-> InitialHandler has connected$`, Type: EventPlayerJoin, Level: "debug", Message: "${player} joined the network"},
+	{Pattern: bungeeLogPrefix("INFO") + `\[(?P<player>[A-Za-z0-9_]{1,16})\] -> UpstreamBridge has disconnected$`, Type: EventPlayerLeave, Level: "debug", Message: "${player} left the network"},
+	// Paper, Spigot and Velocity
+	{Pattern: consoleLogPrefix("INFO") + `Done \((?P<startup_time>[0-9.,]+)s\)!(?: For help, type "help".*)?$`, Type: EventServerReady, Level: "info", Message: "Server ready in ${startup_time}s", registersProxy: true},
+	// BungeeCord
+	{Pattern: bungeeLogPrefix("INFO") + `Listening on /(?P<address>\S+)$`, Type: EventServerReady, Level: "info", Message: "Proxy listening on ${address}", registersProxy: true},
+	{Pattern: consoleLogPrefix("WARN") + `Can't keep up! Is the server overloaded\? Running (?P<lag_ms>\d+)ms or (?P<lag_ticks>\d+) ticks behind$`, Type: EventServerLag, Level: "warn", Message: "Server is running ${lag_ms}ms (${lag_ticks} ticks) behind"},
+	{Pattern: `(?P<exception>(?:[a-z_$][\w$]*\.)+[A-Z][\w$]*(?:Exception|Error))(?::\s*(?P<error>.*))?$`, Type: EventServerException, Level: "debug", StackTrace: true},
+}
+
+// consoleParser triggers events for the console lines of a server matching a rule
+type consoleParser struct {
+	serverName string
+	// pendingEvent is an exception waiting for its stack trace
+	pendingEvent *Event
+	stackTrace   []string
+}
+
+// getConsoleRules returns the default rules and the ones of ConsoleRulesFile
+func getConsoleRules() []*ConsoleRule {
+	consoleRulesOnce.Do(func() {
+		var rules []*ConsoleRule
+
+		if ConsoleDefaultRulesEnabled {
+			rules = append(rules, defaultConsoleRules...)
+		}
+
+		if ConsoleRulesFile != "" {
+			var fileRules []*ConsoleRule
+			jsonBytes, err := ioutil.ReadFile(ConsoleRulesFile)
+			if err == nil {
+				err = json.Unmarshal(jsonBytes, &fileRules)
+			}
+			if err != nil {
+				TriggerLogEvent("severe", "console", fmt.Sprintf("Could not load console rules from %s: %s", ConsoleRulesFile, err))
+			}
+			// Custom rules are checked first so they can override the default ones
+			rules = append(fileRules, rules...)
+		}
+
+		for _, rule := range rules {
+			regex, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				TriggerLogEvent("severe", "console", fmt.Sprintf("Invalid console rule pattern \"%s\": %s", rule.Pattern, err))
+				continue
+			}
+			rule.regex = regex
+			consoleRules = append(consoleRules, rule)
+		}
+	})
+
+	return consoleRules
+}
+
+// startConsoleParsing captures the console of a server and parses it, the capture is cleared for new sessions
+func startConsoleParsing(serverName string, newSession bool) {
+	capturePath, err := filepath.Abs(filepath.Join(ConsoleCaptureDirectory, serverName+".log"))
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(capturePath), 0755)
+	}
+	if err == nil && newSession {
+		err = ioutil.WriteFile(capturePath, nil, 0644)
+	}
+	if err == nil {
+		err = SessionCapture(serverName, capturePath)
+	}
+	if err != nil {
+		TriggerLogEvent("warn", serverName, fmt.Sprintf("Could not capture the console: %s", err))
+		return
+	}
+
+	consoleWatchersLock.Lock()
+	defer consoleWatchersLock.Unlock()
+
+	// Watchers keep running across restarts of the server
+	if !consoleWatchers[serverName] {
+		consoleWatchers[serverName] = true
+		go watchConsole(serverName, capturePath)
+	}
+}
+
+// watchConsole reads the lines added to a console capture, starting from its current end
+func watchConsole(serverName string, capturePath string) {
+	parser := &consoleParser{serverName: serverName}
+
+	var offset int64
+	if fileInfo, err := os.Stat(capturePath); err == nil {
+		offset = fileInfo.Size()
+	}
+	partialLine := ""
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	for range ticker.C {
+		fileInfo, err := os.Stat(capturePath)
+		if err != nil {
+			continue
+		}
+
+		// The capture is cleared when the server is started again
+		if fileInfo.Size() < offset {
+			offset = 0
+			partialLine = ""
+			parser.flush()
+		}
+
+		if fileInfo.Size() == offset {
+			// The stack trace is complete when nothing was written for a while
+			parser.flush()
+
+			// The capture only grows, it's emptied once read while the console is idle
+			if ConsoleCaptureMaxSizeMB > 0 && offset >= ConsoleCaptureMaxSizeMB*1024*1024 {
+				err = os.Truncate(capturePath, 0)
+				if err == nil {
+					offset = 0
+				}
+			}
+			continue
+		}
+
+		file, err := os.Open(capturePath)
+		if err != nil {
+			continue
+		}
+		output, err := ioutil.ReadAll(io.NewSectionReader(file, offset, fileInfo.Size()-offset))
+		file.Close()
+		if err != nil {
+			continue
+		}
+		offset += int64(len(output))
+
+		lines := strings.Split(partialLine+string(output), "\n")
+		partialLine = lines[len(lines)-1]
+		for _, line := range lines[:len(lines)-1] {
+			parser.parseLine(line)
+		}
+	}
+}
+
+// parseLine triggers the event of the first rule matching a line
+func (parser *consoleParser) parseLine(line string) {
+	line = ansiEscapeRegex.ReplaceAllString(line, "")
+	line = strings.TrimRight(line, "\r")
+	// Some consoles redraw their prompt before each line
+	line = strings.TrimPrefix(line, "> ")
+
+	if parser.pendingEvent != nil {
+		if stackTraceLineRegex.MatchString(line) {
+			if len(parser.stackTrace) < maxStackTraceLines {
+				parser.stackTrace = append(parser.stackTrace, strings.TrimSpace(line))
+			}
+			return
+		}
+		parser.flush()
+	}
+
+	for _, rule := range getConsoleRules() {
+		match := rule.regex.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+
+		fields := EventFields{
+			"server": parser.serverName,
+			"line":   strings.TrimSpace(line),
+		}
+		for i, groupName := range rule.regex.SubexpNames() {
+			if groupName != "" && match[2*i] >= 0 {
+				fields[groupName] = line[match[2*i]:match[2*i+1]]
+			}
+		}
+
+		message := strings.TrimSpace(line)
+		if rule.Message != "" {
+			message = string(rule.regex.ExpandString(nil, rule.Message, line, match))
+		}
+
+		level := rule.Level
+		if level == "" {
+			level = "info"
+		}
+
+		event := Event{
+			Type:    rule.Type,
+			Level:   level,
+			Service: parser.serverName,
+			Message: message,
+			Fields:  fields,
+		}
+		if event.Type == "" {
+			event.Type = EventLog
+		}
+
+		if rule.StackTrace {
+			parser.pendingEvent = &event
+			parser.stackTrace = nil
+		} else {
+			TriggerEvent(event.Type, event.Level, event.Service, event.Message, event.Fields)
+		}
+		if rule.registersProxy {
+			proxyServerReady(parser.serverName)
+		}
+		return
+	}
+}
+
+// flush triggers the pending exception event with its stack trace
+func (parser *consoleParser) flush() {
+	if parser.pendingEvent == nil {
+		return
+	}
+
+	event := parser.pendingEvent
+	if len(parser.stackTrace) > 0 {
+		event.Fields["stack_trace"] = strings.Join(parser.stackTrace, "\n")
+	}
+
+	parser.pendingEvent = nil
+	parser.stackTrace = nil
+
+	TriggerEvent(event.Type, event.Level, event.Service, event.Message, event.Fields)
+}
@@ -0,0 +1,39 @@
+package rcsm
+
+import "testing"
+
+func TestDefaultConsoleRules(t *testing.T) {
+	ConsoleDefaultRulesEnabled = true
+	ConsoleRulesFile = ""
+
+	tests := []struct {
+		line      string
+		eventType EventType
+	}{
+		{`[12:34:56 INFO]: Done (3.214s)! For help, type "help"`, EventServerReady},
+		{`[12:34:56] [Server thread/INFO]: Done (3.214s)! For help, type "help"`, EventServerReady},
+		{`[12:34:56 INFO]: Done (1.23s)!`, EventServerReady},
+		{`12:34:56 [INFO] Listening on /0.0.0.0:25577`, EventServerReady},
+		{`[12:34:56 INFO]: Notch joined the game`, EventPlayerJoin},
+		{`[12:34:56 INFO]: [connected player] Notch (/127.0.0.1:51234) has connected`, EventPlayerJoin},
+		{`[12:34:56 WARN]: Can't keep up! Is the server overloaded? Running 2000ms or 40 ticks behind`, EventServerLag},
+		// Chat lines must not fake events
+		{`[12:34:56 INFO]: <Notch> Done (1.0s)!`, ""},
+		{`[12:34:56 INFO]: <Notch> Can't keep up! Is the server overloaded? Running 99999ms or 2000 ticks behind`, ""},
+		{`[12:34:56 INFO]: <Notch> : Jeb joined the game`, ""},
+		{`[12:34:56] [Async Chat Thread - #0/INFO]: <Notch> Done (1.0s)!`, ""},
+	}
+
+	for _, test := range tests {
+		var matched EventType
+		for _, rule := range getConsoleRules() {
+			if rule.regex.MatchString(test.line) {
+				matched = rule.Type
+				break
+			}
+		}
+		if matched != test.eventType {
+			t.Errorf("Line %q matched %q, expected %q", test.line, matched, test.eventType)
+		}
+	}
+}
@@ -1,32 +1,58 @@
 package rcsm
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
 // DiscordWebhookRequest defines the format of a webhook request
 type DiscordWebhookRequest struct {
-	Content string         `json:"content"`
-	Embeds  []DiscordEmbed `json:"embeds"`
+	Content         string                 `json:"content"`
+	Embeds          []DiscordEmbed         `json:"embeds"`
+	AllowedMentions *DiscordAllowedMention `json:"allowed_mentions,omitempty"`
+}
+
+// DiscordWebhookMessage defines the format of a message returned by Discord
+type DiscordWebhookMessage struct {
+	ID string `json:"id"`
 }
 
 // DiscordErrorMessage defines the format of a webhook request
 type DiscordErrorMessage struct {
-	Global     bool   `json:"global"`
-	Message    string `json:"message"`
-	RetryAfter int    `json:"retry_after"`
+	Global     bool    `json:"global"`
+	Message    string  `json:"message"`
+	RetryAfter float64 `json:"retry_after"`
+}
+
+// DiscordAllowedMention defines which roles and users can be pinged by a message
+type DiscordAllowedMention struct {
+	Parse []string `json:"parse"`
+	Roles []string `json:"roles,omitempty"`
+	Users []string `json:"users,omitempty"`
 }
 
 // DiscordEmbed defines the format of a Discord embed message
 type DiscordEmbed struct {
-	Color  int            `json:"color"`
-	Fields []DiscordField `json:"fields"`
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+	Color       int                 `json:"color"`
+	Footer      *DiscordEmbedFooter `json:"footer,omitempty"`
+	Author      *DiscordEmbedAuthor `json:"author,omitempty"`
+	Fields      []DiscordField      `json:"fields,omitempty"`
+}
+
+// DiscordEmbedFooter defines the format of an embed footer
+type DiscordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// DiscordEmbedAuthor defines the format of an embed author
+type DiscordEmbedAuthor struct {
+	Name string `json:"name"`
 }
 
 // DiscordField defines the format of an embed field
@@ -36,77 +62,129 @@ type DiscordField struct {
 	Inline bool   `json:"inline"`
 }
 
+// DiscordNotifier sends events to Discord webhooks
+type DiscordNotifier struct{}
+
+// Name returns the name of the notifier
+func (notifier DiscordNotifier) Name() string {
+	return "discord"
+}
+
+// Endpoints returns the Discord webhook URLs
+func (notifier DiscordNotifier) Endpoints() []string {
+	return splitConfigList(WebhooksEndpoint)
+}
+
+// Notify sends an event to a Discord webhook
+func (notifier DiscordNotifier) Notify(event Event, endpoint string) error {
+	return SendDiscordWebhook(event, endpoint)
+}
+
 // SendDiscordWebhook sends a webhook request to Discord
-func SendDiscordWebhook(level string, service string, message string) error {
-	levelField := DiscordField{
-		Name:   "Level",
-		Value:  level,
-		Inline: true,
+func SendDiscordWebhook(event Event, endpoint string) error {
+	fields := []DiscordField{
+		{
+			Name:   "Level",
+			Value:  event.Level,
+			Inline: true,
+		},
+		{
+			Name:   "Server/Service",
+			Value:  event.Service,
+			Inline: true,
+		},
 	}
-	instanceField := DiscordField{
-		Name:   "Instance",
-		Value:  InstanceName,
-		Inline: true,
-	}
-	serviceField := DiscordField{
-		Name:   "Server/Service",
-		Value:  service,
-		Inline: true,
+
+	if event.Type != EventLog {
+		fields = append(fields, DiscordField{
+			Name:   "Event",
+			Value:  string(event.Type),
+			Inline: true,
+		})
 	}
-	messageField := DiscordField{
-		Name:   "Message",
-		Value:  message,
-		Inline: false,
+
+	for _, fieldName := range sortedEventFieldNames(event.Fields) {
+		value := fmt.Sprintf("%v", event.Fields[fieldName])
+		if value == "" {
+			// Discord rejects embeds with empty fields
+			value = "-"
+		}
+
+		fields = append(fields, DiscordField{
+			Name:   fieldName,
+			Value:  value,
+			Inline: true,
+		})
 	}
 
 	embedMessage := DiscordEmbed{
-		Color:  getColorLevel(strings.ToLower(level)),
-		Fields: []DiscordField{levelField, instanceField, serviceField, messageField},
+		Title:       fmt.Sprintf("%s: %s", event.Service, getDiscordEventTitle(event)),
+		Description: event.Message,
+		Timestamp:   event.Timestamp.Format(time.RFC3339),
+		Color:       getColorLevel(strings.ToLower(event.Level)),
+		Footer:      &DiscordEmbedFooter{Text: fmt.Sprintf("rcsm v%s", Version)},
+		Author:      &DiscordEmbedAuthor{Name: event.Instance},
+		Fields:      fields,
 	}
 
 	discordRequest := DiscordWebhookRequest{
-		Content: "New event",
-		Embeds:  []DiscordEmbed{embedMessage},
+		Embeds: []DiscordEmbed{embedMessage},
+		// Only ping the configured roles and users, even if a message contains other mentions
+		AllowedMentions: &DiscordAllowedMention{Parse: []string{}},
+	}
+
+	if levelRank(event.Level) >= levelRank(WebhooksMentionMinLevel) {
+		var mentions []string
+		for _, role := range splitConfigList(WebhooksMentionRoles) {
+			mentions = append(mentions, fmt.Sprintf("<@&%s>", role))
+			discordRequest.AllowedMentions.Roles = append(discordRequest.AllowedMentions.Roles, role)
+		}
+		for _, user := range splitConfigList(WebhooksMentionUsers) {
+			mentions = append(mentions, fmt.Sprintf("<@%s>", user))
+			discordRequest.AllowedMentions.Users = append(discordRequest.AllowedMentions.Users, user)
+		}
+		discordRequest.Content = strings.Join(mentions, " ")
+	}
+
+	_, err := sendDiscordRequest("POST", endpoint, "", discordRequest)
+	return err
+}
+
+// sendDiscordRequest sends a request to a webhook, or to one of its messages, and waits when rate limited
+func sendDiscordRequest(method string, endpoint string, messageID string, discordRequest DiscordWebhookRequest) ([]byte, error) {
+	requestURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Discord webhook URL")
+	}
+	if messageID != "" {
+		requestURL.Path = strings.TrimSuffix(requestURL.Path, "/") + "/messages/" + messageID
+	} else {
+		// Wait for the message to be created to get its ID
+		query := requestURL.Query()
+		query.Set("wait", "true")
+		requestURL.RawQuery = query.Encode()
 	}
 
 	jsonRequest, err := json.Marshal(discordRequest)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for {
-		response, err := http.Post(WebhooksEndpoint, "application/json", bytes.NewBuffer(jsonRequest))
-		if err != nil {
-			return err
-		}
-		defer response.Body.Close()
-
-		// Detect if we're getting an error
-		if response.StatusCode < 200 || response.StatusCode >= 300 {
-			body, _ := ioutil.ReadAll(response.Body)
-
-			var errorMessage DiscordErrorMessage
-			err = json.Unmarshal(body, &errorMessage)
-			if err != nil {
-				return err
-			}
-
-			// If it's a rate limit, wait and retry
-			if errorMessage.RetryAfter > 0 {
-				sleepDuration := time.Duration(errorMessage.RetryAfter) * time.Millisecond
-				time.Sleep(sleepDuration)
-				continue
-			}
-
-			// Error was not related to rate limiting
-			return fmt.Errorf(string(body))
-		}
+	// Webhook URLs contain a token, the shared helper doesn't log them
+	return sendNotifierRawRequest(method, requestURL.String(), nil, jsonRequest)
+}
 
-		// Code was between 200 and 300, all good
-		break
+// getDiscordEventTitle returns a readable title for an event, such as "Server crashed" for server_crashed
+func getDiscordEventTitle(event Event) string {
+	title := strings.ReplaceAll(string(event.Type), "_", " ")
+	if event.Type == EventLog {
+		title = strings.ToLower(event.Level)
+	}
+	if title == "" {
+		return "Event"
 	}
 
-	return nil
+	return strings.ToUpper(title[:1]) + title[1:]
 }
 
 func getColorLevel(level string) int {
@@ -0,0 +1,146 @@
+package rcsm
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// discordStatusMessages maps hashes of Discord webhooks to the ID of their status message, they are stored in WebhooksStatusMessagesFile
+var discordStatusMessages = make(map[string]string)
+
+// StartDiscordStatus starts a task keeping a Discord message with the state of every server up to date
+func StartDiscordStatus() {
+	loadDiscordStatusMessages()
+
+	ticker := time.NewTicker(time.Duration(WebhooksStatusIntervalSec) * time.Second)
+	go func() {
+		for {
+			updateDiscordStatus()
+			<-ticker.C
+		}
+	}()
+}
+
+// loadDiscordStatusMessages reads the status messages sent before rcsm restarted, so they are edited instead of sent again
+func loadDiscordStatusMessages() {
+	jsonMessages, err := ioutil.ReadFile(WebhooksStatusMessagesFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(jsonMessages, &discordStatusMessages)
+	}
+	if err != nil {
+		log.Printf("Could not read Discord status messages from %s, new ones will be sent: %s", WebhooksStatusMessagesFile, err)
+	}
+}
+
+// saveDiscordStatusMessages stores the IDs of the status messages
+func saveDiscordStatusMessages() {
+	jsonMessages, err := json.Marshal(discordStatusMessages)
+	if err == nil {
+		err = ioutil.WriteFile(WebhooksStatusMessagesFile, jsonMessages, 0600)
+	}
+	if err != nil {
+		log.Printf("Could not save Discord status messages to %s: %s", WebhooksStatusMessagesFile, err)
+	}
+}
+
+// getDiscordStatusKey returns the key of a webhook in discordStatusMessages, the hash keeps its token out of the file
+func getDiscordStatusKey(endpoint string) string {
+	hash := sha256.Sum256([]byte(endpoint))
+	return fmt.Sprintf("%x", hash[:8])
+}
+
+// updateDiscordStatus edits the status messages, they are created on the first update or if they were deleted
+func updateDiscordStatus() {
+	discordRequest := DiscordWebhookRequest{
+		Embeds:          []DiscordEmbed{getDiscordStatusEmbed()},
+		AllowedMentions: &DiscordAllowedMention{Parse: []string{}},
+	}
+
+	endpoints := splitConfigList(WebhooksStatusEndpoints)
+	if len(endpoints) == 0 {
+		endpoints = splitConfigList(WebhooksEndpoint)
+	}
+
+	for _, namedEndpoint := range endpoints {
+		_, endpoint := parseNamedEndpoint(namedEndpoint, "")
+		statusKey := getDiscordStatusKey(endpoint)
+
+		messageID, found := discordStatusMessages[statusKey]
+		if found {
+			_, err := sendDiscordRequest("PATCH", endpoint, messageID, discordRequest)
+			if err == nil {
+				continue
+			}
+
+			// Send a new message if the previous one was deleted
+			requestError, ok := err.(notifierRequestError)
+			if !ok || requestError.statusCode != http.StatusNotFound {
+				log.Printf("Could not update Discord status message: %s", err)
+				continue
+			}
+			delete(discordStatusMessages, statusKey)
+		}
+
+		body, err := sendDiscordRequest("POST", endpoint, "", discordRequest)
+		if err != nil {
+			log.Printf("Could not send Discord status message: %s", err)
+			continue
+		}
+
+		var message DiscordWebhookMessage
+		err = json.Unmarshal(body, &message)
+		if err != nil || message.ID == "" {
+			log.Printf("Could not read the ID of the Discord status message, it will be sent again")
+			continue
+		}
+		discordStatusMessages[statusKey] = message.ID
+		saveDiscordStatusMessages()
+	}
+}
+
+// getDiscordStatusEmbed creates an embed listing servers, their state and uptime
+func getDiscordStatusEmbed() DiscordEmbed {
+	servers := getServersSnapshot()
+
+	color := getColorLevel("info")
+	var lines []string
+	running := 0
+
+	for _, server := range servers {
+		state := "stopped"
+		if server.crashed {
+			state = "crashed"
+			color = getColorLevel("severe")
+		} else if server.running {
+			running++
+			state = "running"
+			if !server.startedAt.IsZero() {
+				state += fmt.Sprintf(" for %s", time.Since(server.startedAt).Round(time.Second))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("**%s**: %s", server.name, state))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "No server found")
+	}
+
+	return DiscordEmbed{
+		Title:       fmt.Sprintf("%d/%d server(s) running", running, len(servers)),
+		Description: strings.Join(lines, "\n"),
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Color:       color,
+		Footer:      &DiscordEmbedFooter{Text: fmt.Sprintf("rcsm v%s, updated every %ds", Version, WebhooksStatusIntervalSec)},
+		Author:      &DiscordEmbedAuthor{Name: InstanceName},
+	}
+}
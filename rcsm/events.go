@@ -1,27 +1,404 @@
 package rcsm
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType defines what happened, so consumers don't have to parse messages
+type EventType string
+
+// Event types sent by rcsm, EventLog is used for events without a specific type
+const (
+	EventLog               EventType = "log"
+	EventRcsmStarted       EventType = "rcsm_started"
+	EventRcsmStopped       EventType = "rcsm_stopped"
+	EventServerStarted     EventType = "server_started"
+	EventServerStopped     EventType = "server_stopped"
+	EventServerStartFailed EventType = "server_start_failed"
+	EventServerStopFailed  EventType = "server_stop_failed"
+	EventServerCrashed     EventType = "server_crashed"
+	EventBootloop          EventType = "bootloop"
+	EventBackupCompleted   EventType = "backup_completed"
+	EventBackupFailed      EventType = "backup_failed"
+	EventTemplateApplied   EventType = "template_applied"
+	EventTemplateFailed    EventType = "template_failed"
+	EventTemplateRejected  EventType = "template_rejected"
+	EventUpdateAvailable   EventType = "update_available"
+	EventUpdateInstalled   EventType = "update_installed"
+	EventRedisConnected    EventType = "redis_connected"
+	EventRedisUnavailable  EventType = "redis_unavailable"
+	EventResolved          EventType = "resolved"
+	EventDigest            EventType = "digest"
+	EventPlayerJoin        EventType = "player_join"
+	EventPlayerLeave       EventType = "player_leave"
+	EventServerReady       EventType = "server_ready"
+	EventServerException   EventType = "exception"
+	EventServerLag         EventType = "lag"
+	EventCommandRejected   EventType = "command_rejected"
+	EventLeaderElected     EventType = "leader_elected"
+	EventLeaderLost        EventType = "leader_lost"
+)
+
+// EventFields defines the structured fields of an event, such as server, duration (in seconds), attempt, error or bytes
+type EventFields map[string]interface{}
+
+// Event defines a log event broadcasted on Redis and Webhooks
+type Event struct {
+	Type      EventType
+	Level     string
+	Instance  string
+	Service   string
+	Message   string
+	Fields    EventFields
+	Timestamp time.Time
+}
+
+// eventSink defines a destination for events, with its own bounded queue and worker
+type eventSink struct {
+	name     string
+	minLevel string
+	queue    chan Event
+	accepts  func(event Event) bool
+	send     func(event Event) error
+	// outboxName is the directory of the outbox, it's based on the endpoint so events are not sent elsewhere when endpoints are reordered
+	outboxName string
+	// suppressor is only set for webhook sinks
+	suppressor *eventSuppressor
+	pending    int64
+	dropped    int64
+}
+
+var (
+	eventSinks     []*eventSink
+	eventSinksOnce sync.Once
 )
 
 // TriggerLogEvent is the method used to log messages so they can be broadcasted on Redis and Webhooks
 func TriggerLogEvent(level string, service string, message string) {
-	level = strings.ToUpper(level)
+	TriggerEvent(EventLog, level, service, message, nil)
+}
+
+// TriggerEvent logs a typed event with structured fields so it can be broadcasted on Redis and Webhooks
+func TriggerEvent(eventType EventType, level string, service string, message string, fields EventFields) {
+	event := Event{
+		Type:      eventType,
+		Level:     strings.ToUpper(level),
+		Instance:  InstanceName,
+		Service:   service,
+		Message:   message,
+		Fields:    fields,
+		Timestamp: time.Now(),
+	}
+
+	writeLogEvent(event)
+
+	for _, sink := range getEventSinks() {
+		if !sink.wants(event) {
+			continue
+		}
+
+		if sink.suppressor != nil {
+			sink.suppressor.filter(event)
+		} else {
+			sink.enqueue(event)
+		}
+	}
+
+	trackEventCondition(event)
+
+	// Fatal events are followed by an exit, make sure they are delivered before
+	if event.Level == "FATAL" {
+		FlushEvents()
+	}
+}
+
+// formatEventFields formats fields for text logs, such as " {attempt=2 server=lobby}"
+func formatEventFields(fields EventFields) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	formattedFields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		formattedFields = append(formattedFields, fmt.Sprintf("%s=%v", key, fields[key]))
+	}
+
+	return fmt.Sprintf(" {%s}", strings.Join(formattedFields, " "))
+}
+
+// FlushEvents waits for queued events to be delivered, up to EventsFlushTimeoutSec
+func FlushEvents() {
+	deadline := time.Now().Add(time.Duration(EventsFlushTimeoutSec) * time.Second)
+
+	for _, sink := range getEventSinks() {
+		if sink.suppressor != nil {
+			sink.suppressor.flush(true)
+		}
+	}
+
+	for _, sink := range getEventSinks() {
+		for atomic.LoadInt64(&sink.pending) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if pending := atomic.LoadInt64(&sink.pending); pending > 0 {
+			log.Printf("Timeout while flushing events, %d event(s) not sent to %s", pending, sink.name)
+		}
+	}
+}
+
+func getEventSinks() []*eventSink {
+	eventSinksOnce.Do(func() {
+		// Events can't be triggered while sinks are being created
+		err := validateEventSinkLevels()
+		if err != nil {
+			log.Fatalf("Invalid event sink levels: %s", err)
+		}
+
+		err = loadEventRoutes()
+		if err != nil {
+			log.Fatalf("Could not load event routes from %s: %s", EventRoutesFile, err)
+		}
+
+		for _, notifier := range getNotifiers() {
+			for i, namedEndpoint := range notifier.Endpoints() {
+				endpointName, endpoint := parseNamedEndpoint(namedEndpoint, strconv.Itoa(i+1))
+				notifier, endpoint := notifier, endpoint
+
+				sink := startEventSink(notifier.Name()+":"+endpointName, getEndpointOutboxName(notifier.Name(), endpoint), "info", func(event Event) bool {
+					return true
+				}, func(event Event) error {
+					return notifier.Notify(event, endpoint)
+				})
+				sink.suppressor = newEventSuppressor(sink)
+			}
+		}
+
+		if SyslogEnabled {
+			startEventSink("syslog", "syslog", "debug", func(event Event) bool {
+				return true
+			}, func(event Event) error {
+				return SendSyslogEvent(event)
+			})
+		}
+
+		if JournaldEnabled {
+			startEventSink("journald", "journald", "debug", func(event Event) bool {
+				return true
+			}, func(event Event) error {
+				return SendJournaldEvent(event)
+			})
+		}
+
+		if isMessageBusEnabled() {
+			startEventSink(getMessageBusName(), getMessageBusName(), "debug", func(event Event) bool {
+				// Events are stored in the outbox until the bus is available
+				return (Bus != nil && Bus.Available()) || EventsOutboxEnabled
+			}, func(event Event) error {
+				return SendBusEvent(event)
+			})
+		}
+	})
+
+	return eventSinks
+}
+
+// getEndpointOutboxName returns the outbox of an endpoint, such as discord-3f2a9c0b1d4e5f60, the hash keeps secrets out of the directory name
+func getEndpointOutboxName(notifierName string, endpoint string) string {
+	hash := sha256.Sum256([]byte(endpoint))
+	return fmt.Sprintf("%s-%x", notifierName, hash[:8])
+}
 
-	log.Printf("[%s][%s][%s] %s", InstanceName, level, service, message)
+func startEventSink(name string, outboxName string, defaultMinLevel string, accepts func(event Event) bool, send func(event Event) error) *eventSink {
+	queueSize := EventsQueueSize
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	sink := &eventSink{
+		name:       name,
+		outboxName: outboxName,
+		minLevel:   getSinkMinLevel(name, defaultMinLevel),
+		queue:      make(chan Event, queueSize),
+		accepts:    accepts,
+		send:       send,
+	}
+	eventSinks = append(eventSinks, sink)
+
+	go sink.work()
 
-	if WebhooksEnabled && strings.ToLower(level) != "debug" {
-		err := SendDiscordWebhook(level, service, message)
+	return sink
+}
+
+// wants checks if an event should be sent to the sink, according to its level and the routing rules
+func (sink *eventSink) wants(event Event) bool {
+	return levelRank(event.Level) >= levelRank(sink.minLevel) && isEventRouted(sink.name, event) && sink.accepts(event)
+}
+
+// enqueue adds an event to the sink queue, applying EventsQueuePolicy when the queue is full
+func (sink *eventSink) enqueue(event Event) {
+	atomic.AddInt64(&sink.pending, 1)
+
+	switch strings.ToLower(EventsQueuePolicy) {
+	case "block":
+		sink.queue <- event
+	case "drop-oldest":
+		for {
+			select {
+			case sink.queue <- event:
+				return
+			default:
+			}
+
+			// Make room by dropping the oldest event
+			select {
+			case <-sink.queue:
+				atomic.AddInt64(&sink.dropped, 1)
+				atomic.AddInt64(&sink.pending, -1)
+			default:
+			}
+		}
+	default:
+		select {
+		case sink.queue <- event:
+		default:
+			atomic.AddInt64(&sink.dropped, 1)
+			atomic.AddInt64(&sink.pending, -1)
+		}
+	}
+}
+
+func (sink *eventSink) work() {
+	if EventsOutboxEnabled {
+		outbox, err := newEventOutbox(sink.outboxName)
+		if err == nil {
+			sink.workWithOutbox(outbox)
+			return
+		}
+		log.Printf("Could not open the outbox of %s, undelivered events will be lost: %s", sink.name, err)
+	}
+
+	for event := range sink.queue {
+		sink.logDroppedEvents()
+
+		err := sink.send(event)
 		if err != nil {
-			log.Printf("Error while sending webhook: %s", err)
+			log.Printf("Error while sending event to %s: %s", sink.name, err)
 		}
+
+		atomic.AddInt64(&sink.pending, -1)
 	}
+}
+
+// workWithOutbox sends events, storing them on disk when the sink is down and retrying with an exponential backoff
+func (sink *eventSink) workWithOutbox(outbox *eventOutbox) {
+	retryDelay := time.Second
+	maxRetryDelay := time.Duration(EventsOutboxMaxRetryDelaySec) * time.Second
+	// attempts counts the failed deliveries of the oldest event
+	attempts := int64(0)
+
+	for {
+		if outbox.isEmpty() {
+			event := <-sink.queue
+			sink.logDroppedEvents()
+
+			err := sink.send(event)
+			if err != nil && isPermanentEventError(err) {
+				log.Printf("Error while sending event to %s, dropping it since it would fail again: %s", sink.name, err)
+			} else if err != nil {
+				log.Printf("Error while sending event to %s, storing it in the outbox: %s", sink.name, err)
+				sink.storeInOutbox(outbox, event)
+			}
+
+			atomic.AddInt64(&sink.pending, -1)
+			continue
+		}
+
+		// Queued events go after the ones already in the outbox to keep them in order
+		sink.moveQueueToOutbox(outbox)
 
-	if RedisAvailable {
-		err := SendRedisEvent(level, service, message)
+		event, err := outbox.peek()
 		if err != nil {
-			log.Printf("Error while sending Redis pub: %s", err)
+			log.Printf("Could not read event from the outbox of %s, dropping it: %s", sink.name, err)
+			outbox.pop()
+			continue
+		}
+
+		err = sink.send(event)
+		if err == nil {
+			outbox.pop()
+			retryDelay = time.Second
+			attempts = 0
+			continue
+		}
+
+		// Events that can't be delivered are set aside, they would block the following ones
+		attempts++
+		if isPermanentEventError(err) || (EventsOutboxMaxAttempts > 0 && attempts >= EventsOutboxMaxAttempts) {
+			log.Printf("Could not send event from the outbox of %s after %d attempt(s), moving it to the failed events: %s", sink.name, attempts, err)
+			outbox.deadLetter()
+			retryDelay = time.Second
+			attempts = 0
+			continue
+		}
+
+		log.Printf("Error while sending event from the outbox of %s, retrying in %s: %s", sink.name, retryDelay, err)
+
+		retry := time.After(retryDelay)
+	waitForRetry:
+		for {
+			select {
+			case event := <-sink.queue:
+				sink.storeInOutbox(outbox, event)
+				atomic.AddInt64(&sink.pending, -1)
+			case <-retry:
+				break waitForRetry
+			}
+		}
+
+		retryDelay *= 2
+		if retryDelay > maxRetryDelay {
+			retryDelay = maxRetryDelay
+		}
+	}
+}
+
+func (sink *eventSink) moveQueueToOutbox(outbox *eventOutbox) {
+	for {
+		select {
+		case event := <-sink.queue:
+			sink.storeInOutbox(outbox, event)
+			atomic.AddInt64(&sink.pending, -1)
+		default:
+			return
 		}
 	}
 }
+
+func (sink *eventSink) storeInOutbox(outbox *eventOutbox, event Event) {
+	err := outbox.push(event)
+	if err != nil {
+		log.Printf("Could not store event in the outbox of %s, dropping it: %s", sink.name, err)
+	}
+}
+
+func (sink *eventSink) logDroppedEvents() {
+	// Log directly, triggering an event here could fill the queue again
+	if dropped := atomic.SwapInt64(&sink.dropped, 0); dropped > 0 {
+		log.Printf("Event queue of %s was full, dropped %d event(s)", sink.name, dropped)
+	}
+}
@@ -50,14 +50,22 @@ func runHealthCheck() {
 			}
 			server.restartTries++
 
+			unregisterProxyServer(server)
+
 			if server.restartTries > AutoRestartCrashMaxTries {
-				TriggerLogEvent("severe", serverName, "Server crash bootloop detected")
+				TriggerEvent(EventBootloop, "severe", serverName, "Server crash bootloop detected", EventFields{
+					"server":  serverName,
+					"attempt": server.restartTries,
+				})
 				server.running = false
 				server.crashed = true
 			} else {
-				TriggerLogEvent("warn", serverName, "Server is stopped, restarting")
-				if S3Enabled {
-					UpdateTemplate(serverName)
+				TriggerEvent(EventServerCrashed, "warn", serverName, "Server is stopped, restarting", EventFields{
+					"server":  serverName,
+					"attempt": server.restartTries,
+				})
+				if TemplatesEnabled {
+					server.templateFailed = UpdateTemplate(serverName) != nil
 				}
 				startServer(server)
 			}
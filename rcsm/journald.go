@@ -0,0 +1,58 @@
+package rcsm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// journaldFieldNameRegex matches characters that are not allowed in journald field names
+var journaldFieldNameRegex = regexp.MustCompile(`[^A-Z0-9_]`)
+
+var journaldSocket *eventSocket
+
+// SendJournaldEvent sends an event to journald using its native protocol, so it can be filtered with fields
+func SendJournaldEvent(event Event) error {
+	if journaldSocket == nil {
+		journaldSocket = &eventSocket{network: "unixgram", address: JournaldSocket}
+	}
+
+	priority, found := eventSeverities[event.Level]
+	if !found {
+		priority = eventSeverities["INFO"]
+	}
+
+	var message bytes.Buffer
+	writeJournaldField(&message, "MESSAGE", event.Message)
+	writeJournaldField(&message, "PRIORITY", strconv.Itoa(priority))
+	writeJournaldField(&message, "SYSLOG_IDENTIFIER", SyslogAppName)
+	writeJournaldField(&message, "RCSM_INSTANCE", event.Instance)
+	writeJournaldField(&message, "RCSM_SERVER", event.Service)
+	writeJournaldField(&message, "RCSM_LEVEL", event.Level)
+	writeJournaldField(&message, "RCSM_EVENT", string(event.Type))
+
+	for _, fieldName := range sortedEventFieldNames(event.Fields) {
+		journaldFieldName := "RCSM_FIELD_" + journaldFieldNameRegex.ReplaceAllString(strings.ToUpper(fieldName), "_")
+		if len(journaldFieldName) > 64 {
+			journaldFieldName = journaldFieldName[:64]
+		}
+		writeJournaldField(&message, journaldFieldName, fmt.Sprintf("%v", event.Fields[fieldName]))
+	}
+
+	return journaldSocket.write(message.Bytes())
+}
+
+// writeJournaldField adds a field to a journald message, values with line breaks are sent with their size
+func writeJournaldField(message *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(message, "%s=%s\n", name, value)
+		return
+	}
+
+	message.WriteString(name + "\n")
+	binary.Write(message, binary.LittleEndian, uint64(len(value)))
+	message.WriteString(value + "\n")
+}
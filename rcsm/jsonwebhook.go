@@ -0,0 +1,56 @@
+package rcsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"text/template"
+)
+
+var (
+	jsonWebhookTemplate     *template.Template
+	jsonWebhookTemplateOnce sync.Once
+	jsonWebhookTemplateErr  error
+)
+
+// JSONWebhookNotifier sends events to generic HTTP webhooks, as JSON or using a custom template
+type JSONWebhookNotifier struct{}
+
+// Name returns the name of the notifier
+func (notifier JSONWebhookNotifier) Name() string {
+	return "json"
+}
+
+// Endpoints returns the webhook URLs
+func (notifier JSONWebhookNotifier) Endpoints() []string {
+	return splitConfigList(JSONWebhooksEndpoints)
+}
+
+// Notify sends an event to a webhook, using the same format as Redis messages unless a template is set
+func (notifier JSONWebhookNotifier) Notify(event Event, endpoint string) error {
+	if JSONWebhooksTemplate == "" {
+		return sendNotifierRequest("POST", endpoint, nil, newRedisMessage(event))
+	}
+
+	jsonWebhookTemplateOnce.Do(func() {
+		jsonWebhookTemplate, jsonWebhookTemplateErr = template.New("webhook").Funcs(template.FuncMap{
+			"json": func(value interface{}) (string, error) {
+				encoded, err := json.Marshal(value)
+				return string(encoded), err
+			},
+		}).Parse(JSONWebhooksTemplate)
+	})
+	if jsonWebhookTemplateErr != nil {
+		return fmt.Errorf("Invalid JSON_WEBHOOKS_TEMPLATE: %s", jsonWebhookTemplateErr)
+	}
+
+	var body bytes.Buffer
+	err := jsonWebhookTemplate.Execute(&body, event)
+	if err != nil {
+		return err
+	}
+
+	_, err = sendNotifierRawRequest("POST", endpoint, nil, body.Bytes())
+	return err
+}
@@ -0,0 +1,156 @@
+package rcsm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// leaderRenewScript extends the lease only if this instance still holds it
+	leaderRenewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	// leaderReleaseScript deletes the lease only if this instance still holds it
+	leaderReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+var (
+	isLeader   bool
+	leaderLock sync.Mutex
+	// leaderID identifies this process in the lease, so a restarted rcsm doesn't renew the lease of the previous one
+	leaderID string
+)
+
+// StartLeaderElection starts a task acquiring or renewing the leader lease, the leader runs the global jobs
+func StartLeaderElection() {
+	leaderID = getLeaderID()
+
+	ticker := time.NewTicker(time.Duration(RedisLeaderRenewIntervalSec) * time.Second)
+	go func() {
+		for {
+			runLeaderElection()
+			<-ticker.C
+		}
+	}()
+}
+
+// ReleaseLeadership deletes the lease if this instance is the leader, so another instance takes over without waiting for it to expire
+func ReleaseLeadership() {
+	if !IsLeader() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := leaderReleaseScript.Run(ctx, RedisClient, []string{RedisLeaderKey}, leaderID).Err()
+	if err != nil {
+		TriggerLogEvent("warn", "leader", fmt.Sprintf("Could not release the leader lease: %s", err))
+	}
+	setLeader(false, "info", "Released the leader lease", nil)
+}
+
+// IsLeader returns true if this instance should run global jobs, every instance is the leader if leader election is disabled
+func IsLeader() bool {
+	if !RedisEnabled || !RedisLeaderElectionEnabled {
+		return true
+	}
+
+	leaderLock.Lock()
+	defer leaderLock.Unlock()
+
+	return isLeader
+}
+
+// RegisterGlobalJob runs a job every interval on the leader only, it's meant for data shared by every instance, such as the registry
+func RegisterGlobalJob(name string, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if !IsLeader() {
+				continue
+			}
+
+			TriggerLogEvent("debug", "leader", fmt.Sprintf("Running global job %s", name))
+			job()
+		}
+	}()
+}
+
+// runLeaderElection renews the lease of the leader, or tries to acquire it
+func runLeaderElection() {
+	if !isRedisAvailable() {
+		setLeader(false, "warn", "Lost the leader lease, Redis is unavailable", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ttl := time.Duration(RedisLeaderLeaseTTLSec) * time.Second
+
+	// The lease is renewed if this instance holds it, even after a renewal failed
+	renewed, err := leaderRenewScript.Run(ctx, RedisClient, []string{RedisLeaderKey}, leaderID, ttl.Milliseconds()).Int()
+	if err != nil {
+		// Another instance can't take over before the lease expires, but jobs are stopped right away to be safe
+		setLeader(false, "warn", fmt.Sprintf("Lost the leader lease: %s", err), EventFields{"error": err.Error()})
+		return
+	}
+	if renewed == 1 {
+		setLeader(true, "info", "This instance is now the leader", nil)
+		return
+	}
+	setLeader(false, "warn", "Lost the leader lease, it expired or is held by another instance", nil)
+
+	acquired, err := RedisClient.SetNX(ctx, RedisLeaderKey, leaderID, ttl).Result()
+	if err != nil {
+		TriggerLogEvent("warn", "leader", fmt.Sprintf("Could not acquire the leader lease: %s", err))
+		return
+	}
+	if acquired {
+		setLeader(true, "info", "This instance is now the leader", nil)
+	}
+}
+
+// setLeader updates the leadership of this instance, an event is triggered when it changes
+func setLeader(leader bool, level string, message string, fields EventFields) {
+	leaderLock.Lock()
+	wasLeader := isLeader
+	isLeader = leader
+	leaderLock.Unlock()
+
+	if wasLeader == leader {
+		return
+	}
+
+	if fields == nil {
+		fields = EventFields{}
+	}
+	fields["leader"] = leaderID
+
+	if leader {
+		TriggerEvent(EventLeaderElected, level, "leader", message, fields)
+	} else {
+		TriggerEvent(EventLeaderLost, level, "leader", message, fields)
+	}
+}
+
+// getLeaderID returns the value stored in the lease, such as survival@host1:1234
+func getLeaderID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "rcsm"
+	}
+	return fmt.Sprintf("%s@%s:%d", InstanceName, hostname, os.Getpid())
+}
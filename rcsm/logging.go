@@ -0,0 +1,186 @@
+package rcsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logWriter is where logs are written, stderr unless LogFile is set
+var (
+	logWriter     io.Writer = os.Stderr
+	logWriterLock sync.Mutex
+)
+
+// LogLine defines the format of a JSON log line
+type LogLine struct {
+	Timestamp string      `json:"timestamp"`
+	Level     string      `json:"level"`
+	Instance  string      `json:"instance"`
+	Service   string      `json:"service"`
+	Type      EventType   `json:"type"`
+	Message   string      `json:"message"`
+	Fields    EventFields `json:"fields,omitempty"`
+}
+
+// SetupLogging applies the log config, it should be called right after reading it
+func SetupLogging() {
+	if LogFile != "" {
+		file, err := newRotatingLogFile(LogFile, LogFileMaxSizeMB*1024*1024, LogFileMaxBackups)
+		if err != nil {
+			log.Fatalf("Could not open log file %s: %s", LogFile, err)
+		}
+		logWriter = file
+	}
+
+	if strings.ToLower(LogFormat) == "json" {
+		// Logs written with the log package, such as event delivery errors, are converted to JSON as well
+		log.SetFlags(0)
+		log.SetOutput(jsonLogAdapter{})
+	} else {
+		log.SetOutput(logWriter)
+	}
+}
+
+// writeLogEvent logs an event with the configured format, unless its level is below LogLevel
+func writeLogEvent(event Event) {
+	if levelRank(event.Level) < levelRank(LogLevel) {
+		return
+	}
+
+	if strings.ToLower(LogFormat) != "json" {
+		log.Printf("[%s][%s][%s] %s%s", event.Instance, event.Level, event.Service, event.Message, formatEventFields(event.Fields))
+		return
+	}
+
+	writeJSONLogLine(LogLine{
+		Timestamp: event.Timestamp.Format(time.RFC3339Nano),
+		Level:     event.Level,
+		Instance:  event.Instance,
+		Service:   event.Service,
+		Type:      event.Type,
+		Message:   event.Message,
+		Fields:    event.Fields,
+	})
+}
+
+func writeJSONLogLine(line LogLine) {
+	jsonLine, err := json.Marshal(line)
+	if err != nil {
+		// Fields can contain values that can't be encoded, log the event without them
+		line.Fields = EventFields{"error": fmt.Sprintf("Could not encode fields: %s", err)}
+		jsonLine, _ = json.Marshal(line)
+	}
+
+	logWriterLock.Lock()
+	defer logWriterLock.Unlock()
+	logWriter.Write(append(jsonLine, '\n'))
+}
+
+// jsonLogAdapter converts lines written with the log package to JSON log lines
+type jsonLogAdapter struct{}
+
+func (adapter jsonLogAdapter) Write(message []byte) (int, error) {
+	writeJSONLogLine(LogLine{
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Level:     "WARN",
+		Instance:  InstanceName,
+		Service:   "rcsm",
+		Type:      EventLog,
+		Message:   strings.TrimSuffix(string(message), "\n"),
+	})
+	return len(message), nil
+}
+
+// rotatingLogFile is a log file renamed with a number once it reaches its maximum size, such as rcsm.log.1
+type rotatingLogFile struct {
+	lock       sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int64
+	file       *os.File
+	size       int64
+}
+
+func newRotatingLogFile(path string, maxSize int64, maxBackups int64) (*rotatingLogFile, error) {
+	logFile := &rotatingLogFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	return logFile, logFile.open()
+}
+
+func (logFile *rotatingLogFile) open() error {
+	file, err := os.OpenFile(logFile.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	logFile.file = file
+	logFile.size = fileInfo.Size()
+
+	return nil
+}
+
+func (logFile *rotatingLogFile) Write(message []byte) (int, error) {
+	logFile.lock.Lock()
+	defer logFile.lock.Unlock()
+
+	if logFile.maxSize > 0 && logFile.size > 0 && logFile.size+int64(len(message)) > logFile.maxSize {
+		err := logFile.rotate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not rotate log file %s: %s\n", logFile.path, err)
+		}
+	}
+
+	written, err := logFile.file.Write(message)
+	logFile.size += int64(written)
+
+	return written, err
+}
+
+// rotate shifts the previous log files, the oldest one is deleted once there are more than maxBackups
+func (logFile *rotatingLogFile) rotate() error {
+	logFile.file.Close()
+
+	os.Remove(fmt.Sprintf("%s.%d", logFile.path, logFile.maxBackups))
+	for i := logFile.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", logFile.path, i), fmt.Sprintf("%s.%d", logFile.path, i+1))
+	}
+
+	if logFile.maxBackups > 0 {
+		err := os.Rename(logFile.path, logFile.path+".1")
+		if err != nil {
+			return logFile.reopen(err)
+		}
+	} else {
+		err := os.Remove(logFile.path)
+		if err != nil {
+			return logFile.reopen(err)
+		}
+	}
+
+	return logFile.open()
+}
+
+// reopen keeps writing to the current log file when it could not be rotated
+func (logFile *rotatingLogFile) reopen(rotateErr error) error {
+	err := logFile.open()
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
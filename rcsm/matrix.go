@@ -0,0 +1,65 @@
+package rcsm
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// matrixTransactionCounter makes transaction IDs unique when several events are sent in the same nanosecond
+var matrixTransactionCounter int64
+
+// MatrixMessage defines the format of a Matrix m.room.message event
+type MatrixMessage struct {
+	MessageType   string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// MatrixNotifier sends events to Matrix rooms using the client-server API
+type MatrixNotifier struct{}
+
+// Name returns the name of the notifier
+func (notifier MatrixNotifier) Name() string {
+	return "matrix"
+}
+
+// Endpoints returns the Matrix room IDs
+func (notifier MatrixNotifier) Endpoints() []string {
+	return splitConfigList(MatrixRooms)
+}
+
+// Notify sends an event to a Matrix room
+func (notifier MatrixNotifier) Notify(event Event, endpoint string) error {
+	formattedBody := fmt.Sprintf("<b>[%s][%s][%s]</b> %s",
+		html.EscapeString(event.Instance), html.EscapeString(event.Level), html.EscapeString(event.Service), html.EscapeString(event.Message))
+
+	if event.Type != EventLog {
+		formattedBody += fmt.Sprintf("<br><b>Event:</b> %s", html.EscapeString(string(event.Type)))
+	}
+
+	for _, fieldName := range sortedEventFieldNames(event.Fields) {
+		formattedBody += fmt.Sprintf("<br><b>%s:</b> %s", html.EscapeString(fieldName), html.EscapeString(fmt.Sprintf("%v", event.Fields[fieldName])))
+	}
+
+	message := MatrixMessage{
+		MessageType:   "m.text",
+		Body:          formatEventText(event),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formattedBody,
+	}
+
+	transactionID := fmt.Sprintf("rcsm%d.%d", time.Now().UnixNano(), atomic.AddInt64(&matrixTransactionCounter, 1))
+	requestURL := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimSuffix(MatrixHomeserver, "/"), url.PathEscape(endpoint), transactionID)
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + MatrixAccessToken,
+	}
+
+	return sendNotifierRequest("PUT", requestURL, headers, message)
+}
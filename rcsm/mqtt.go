@@ -0,0 +1,120 @@
+package rcsm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttTimeout is how long publishing or subscribing waits for the broker
+const mqttTimeout = 5 * time.Second
+
+// mqttBus sends messages with MQTT topics
+type mqttBus struct {
+	client            mqtt.Client
+	subscriptions     map[string]callbackFunc
+	subscriptionsLock sync.Mutex
+	connectedOnce     bool
+}
+
+// connectMQTT connects to the MQTT broker in the background, topics are subscribed again on every connection
+func connectMQTT() (MessageBus, error) {
+	TriggerLogEvent("debug", "mqtt", fmt.Sprintf("Connecting to %s", MQTTBroker))
+
+	brokers := splitConfigList(MQTTBroker)
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("MQTT_BROKER is empty")
+	}
+
+	clientID := MQTTClientID
+	if clientID == "" {
+		clientID = "rcsm-" + InstanceName
+	}
+
+	bus := &mqttBus{subscriptions: make(map[string]callbackFunc)}
+
+	options := mqtt.NewClientOptions().
+		SetClientID(clientID).
+		SetUsername(MQTTUsername).
+		SetPassword(MQTTPassword).
+		SetCleanSession(true).
+		// Commands can take a while, messages are handled in their own goroutine
+		SetOrderMatters(false).
+		// rcsm starts even if the broker is down, like with Redis
+		SetConnectRetry(true).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(time.Minute).
+		SetOnConnectHandler(bus.onConnect).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			TriggerLogEvent("severe", "mqtt", fmt.Sprintf("MQTT is unavailable, retrying in the background: %s", err))
+		})
+	for _, broker := range brokers {
+		options.AddBroker(broker)
+	}
+
+	bus.client = mqtt.NewClient(options)
+	bus.client.Connect()
+
+	return bus, nil
+}
+
+// onConnect subscribes topics again, the broker forgets them with clean sessions
+func (bus *mqttBus) onConnect(client mqtt.Client) {
+	bus.subscriptionsLock.Lock()
+	defer bus.subscriptionsLock.Unlock()
+
+	if bus.connectedOnce {
+		TriggerLogEvent("info", "mqtt", "Reconnected to the MQTT broker")
+	} else {
+		TriggerLogEvent("debug", "mqtt", "Connected to the MQTT broker")
+	}
+	bus.connectedOnce = true
+
+	for topic, callback := range bus.subscriptions {
+		err := bus.subscribe(topic, callback)
+		if err != nil {
+			TriggerLogEvent("severe", "mqtt", fmt.Sprintf("Could not subscribe to %s: %s", topic, err))
+		}
+	}
+}
+
+func (bus *mqttBus) Name() string {
+	return "mqtt"
+}
+
+func (bus *mqttBus) Available() bool {
+	return bus.client.IsConnectionOpen()
+}
+
+func (bus *mqttBus) Publish(channel string, payload string) error {
+	token := bus.client.Publish(channel, byte(MQTTQoS), false, payload)
+	if !token.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("timeout while publishing on %s", channel)
+	}
+	return token.Error()
+}
+
+func (bus *mqttBus) Subscribe(channel string, callback callbackFunc) error {
+	bus.subscriptionsLock.Lock()
+	defer bus.subscriptionsLock.Unlock()
+
+	bus.subscriptions[channel] = callback
+
+	// Topics are subscribed once connected otherwise
+	if !bus.client.IsConnectionOpen() {
+		return nil
+	}
+	return bus.subscribe(channel, callback)
+}
+
+func (bus *mqttBus) subscribe(topic string, callback callbackFunc) error {
+	token := bus.client.Subscribe(topic, byte(MQTTQoS), func(client mqtt.Client, message mqtt.Message) {
+		go callback(message.Topic(), string(message.Payload()))
+	})
+	if !token.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("timeout while subscribing to %s", topic)
+	}
+	return token.Error()
+}
@@ -0,0 +1,66 @@
+package rcsm
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	mqttserver "github.com/mochi-co/mqtt/server"
+	"github.com/mochi-co/mqtt/server/listeners"
+)
+
+func TestMQTTPublishSubscribe(t *testing.T) {
+	// Find a free port for the broker
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	broker := mqttserver.New()
+	err = broker.AddListener(listeners.NewTCP("test", address), nil)
+	if err != nil {
+		t.Fatalf("Could not start the MQTT broker: %s", err)
+	}
+	go broker.Serve()
+	defer broker.Close()
+
+	MQTTBroker = "tcp://" + address
+	bus, err := connectMQTT()
+	if err != nil {
+		t.Fatalf("Could not connect to MQTT: %s", err)
+	}
+	defer bus.(*mqttBus).client.Disconnect(0)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !bus.Available() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout while connecting to the MQTT broker")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	received := make(chan [2]string, 1)
+	// Subscribing waits for the broker once connected, so the message can't be published before
+	err = bus.Subscribe("rcsm/test", func(channel string, payload string) {
+		received <- [2]string{channel, payload}
+	})
+	if err != nil {
+		t.Fatalf("Could not subscribe: %s", err)
+	}
+
+	err = bus.Publish("rcsm/test", "hello")
+	if err != nil {
+		t.Fatalf("Could not publish: %s", err)
+	}
+
+	select {
+	case message := <-received:
+		if message[0] != "rcsm/test" || message[1] != "hello" {
+			t.Fatalf("Unexpected message %q on %q", message[1], message[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout while waiting for the message")
+	}
+}
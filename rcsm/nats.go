@@ -0,0 +1,81 @@
+package rcsm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsBus sends messages with NATS core subjects
+type natsBus struct {
+	conn *nats.Conn
+}
+
+// connectNATS connects to NATS, the client reconnects and subscribes again on its own
+func connectNATS() (MessageBus, error) {
+	TriggerLogEvent("debug", "nats", fmt.Sprintf("Connecting to %s", NATSURL))
+
+	options := []nats.Option{
+		nats.Name(fmt.Sprintf("rcsm %s", InstanceName)),
+		// rcsm starts even if NATS is down, like with Redis
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2 * time.Second),
+		nats.ConnectHandler(func(conn *nats.Conn) {
+			TriggerLogEvent("debug", "nats", fmt.Sprintf("Connected to %s", conn.ConnectedUrl()))
+		}),
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			if err != nil {
+				TriggerLogEvent("severe", "nats", fmt.Sprintf("NATS is unavailable, retrying in the background: %s", err))
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			TriggerLogEvent("info", "nats", fmt.Sprintf("Reconnected to %s", conn.ConnectedUrl()))
+		}),
+	}
+
+	if NATSUser != "" {
+		options = append(options, nats.UserInfo(NATSUser, NATSPassword))
+	}
+	if NATSToken != "" {
+		options = append(options, nats.Token(NATSToken))
+	}
+	if NATSCredentialsFile != "" {
+		options = append(options, nats.UserCredentials(NATSCredentialsFile))
+	}
+	if NATSTLSCAFile != "" {
+		options = append(options, nats.RootCAs(NATSTLSCAFile))
+	}
+	if NATSTLSCertFile != "" || NATSTLSKeyFile != "" {
+		options = append(options, nats.ClientCert(NATSTLSCertFile, NATSTLSKeyFile))
+	}
+
+	// NATS separates servers with commas
+	conn, err := nats.Connect(strings.Join(splitConfigList(NATSURL), ","), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return natsBus{conn: conn}, nil
+}
+
+func (bus natsBus) Name() string {
+	return "nats"
+}
+
+func (bus natsBus) Available() bool {
+	return bus.conn.IsConnected()
+}
+
+func (bus natsBus) Publish(channel string, payload string) error {
+	return bus.conn.Publish(channel, []byte(payload))
+}
+
+func (bus natsBus) Subscribe(channel string, callback callbackFunc) error {
+	_, err := bus.conn.Subscribe(channel, func(message *nats.Msg) {
+		go callback(message.Subject, string(message.Data))
+	})
+	return err
+}
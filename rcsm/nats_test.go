@@ -0,0 +1,46 @@
+package rcsm
+
+import (
+	"testing"
+	"time"
+
+	natstest "github.com/nats-io/nats-server/v2/test"
+)
+
+func TestNATSPublishSubscribe(t *testing.T) {
+	server := natstest.RunRandClientPortServer()
+	defer server.Shutdown()
+
+	NATSURL = server.ClientURL()
+	bus, err := connectNATS()
+	if err != nil {
+		t.Fatalf("Could not connect to NATS: %s", err)
+	}
+	defer bus.(natsBus).conn.Close()
+
+	if !bus.Available() {
+		t.Fatal("NATS should be available once connected")
+	}
+
+	received := make(chan [2]string, 1)
+	err = bus.Subscribe("rcsm.test", func(channel string, payload string) {
+		received <- [2]string{channel, payload}
+	})
+	if err != nil {
+		t.Fatalf("Could not subscribe: %s", err)
+	}
+
+	err = bus.Publish("rcsm.test", "hello")
+	if err != nil {
+		t.Fatalf("Could not publish: %s", err)
+	}
+
+	select {
+	case message := <-received:
+		if message[0] != "rcsm.test" || message[1] != "hello" {
+			t.Fatalf("Unexpected message %q on %q", message[1], message[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout while waiting for the message")
+	}
+}
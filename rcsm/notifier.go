@@ -0,0 +1,161 @@
+package rcsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifier defines a chat or webhook service events can be sent to
+type Notifier interface {
+	// Name returns the name of the notifier, used in logs
+	Name() string
+	// Endpoints returns the endpoints events are sent to, such as webhook URLs, rooms or chats
+	Endpoints() []string
+	// Notify sends an event to one of the endpoints
+	Notify(event Event, endpoint string) error
+}
+
+// getNotifiers returns the enabled notifiers
+func getNotifiers() []Notifier {
+	var notifiers []Notifier
+
+	if WebhooksEnabled {
+		notifiers = append(notifiers, DiscordNotifier{})
+	}
+	if SlackEnabled {
+		notifiers = append(notifiers, SlackNotifier{})
+	}
+	if MatrixEnabled {
+		notifiers = append(notifiers, MatrixNotifier{})
+	}
+	if TelegramEnabled {
+		notifiers = append(notifiers, TelegramNotifier{})
+	}
+	if JSONWebhooksEnabled {
+		notifiers = append(notifiers, JSONWebhookNotifier{})
+	}
+
+	return notifiers
+}
+
+// formatEventText formats an event as plain text for chat services
+func formatEventText(event Event) string {
+	text := fmt.Sprintf("[%s][%s][%s] %s", event.Instance, event.Level, event.Service, event.Message)
+
+	if event.Type != EventLog {
+		text += fmt.Sprintf("\nEvent: %s", event.Type)
+	}
+
+	for _, fieldName := range sortedEventFieldNames(event.Fields) {
+		text += fmt.Sprintf("\n%s: %v", fieldName, event.Fields[fieldName])
+	}
+
+	return text
+}
+
+func sortedEventFieldNames(fields EventFields) []string {
+	fieldNames := make([]string, 0, len(fields))
+	for fieldName := range fields {
+		fieldNames = append(fieldNames, fieldName)
+	}
+	sort.Strings(fieldNames)
+
+	return fieldNames
+}
+
+const (
+	// notifierTimeout is how long a notification request can take, the next events of the endpoint wait for it
+	notifierTimeout = 15 * time.Second
+	// notifierMaxRateLimitRetries is how many times a rate limited request is sent again before it fails
+	notifierMaxRateLimitRetries = 5
+)
+
+// notifierHTTPClient sends the requests of every notifier
+var notifierHTTPClient = &http.Client{Timeout: notifierTimeout}
+
+// notifierRequestError is returned when a notification service answers with an error that is not a rate limit
+type notifierRequestError struct {
+	statusCode int
+	status     string
+	body       string
+}
+
+func (err notifierRequestError) Error() string {
+	return fmt.Sprintf("Unexpected status %s: %s", err.status, err.body)
+}
+
+func (err notifierRequestError) permanent() bool {
+	return isPermanentStatus(err.statusCode)
+}
+
+// sendNotifierRequest sends a JSON request and retries when rate limited with a Retry-After header
+func sendNotifierRequest(method string, requestURL string, headers map[string]string, payload interface{}) error {
+	jsonRequest, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = sendNotifierRawRequest(method, requestURL, headers, jsonRequest)
+	return err
+}
+
+// sendNotifierRawRequest sends an already encoded JSON request and retries a few times when rate limited, it returns the body of the response
+func sendNotifierRawRequest(method string, requestURL string, headers map[string]string, jsonRequest []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequest(method, requestURL, bytes.NewBuffer(jsonRequest))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		for header, value := range headers {
+			request.Header.Set(header, value)
+		}
+
+		response, err := notifierHTTPClient.Do(request)
+		if urlError, ok := err.(*url.Error); ok {
+			// URLs can contain secrets such as tokens, don't log them
+			return nil, fmt.Errorf("%s request failed: %s", method, urlError.Err)
+		} else if err != nil {
+			return nil, err
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		// If it's a rate limit, wait and retry, the event is kept in the outbox if it keeps failing
+		if response.StatusCode == http.StatusTooManyRequests && attempt < notifierMaxRateLimitRetries {
+			retryAfter := getNotifierRetryAfter(response, body)
+			if retryAfter > 0 {
+				time.Sleep(retryAfter)
+				continue
+			}
+		}
+
+		if response.StatusCode < 200 || response.StatusCode >= 300 {
+			return nil, notifierRequestError{statusCode: response.StatusCode, status: response.Status, body: strings.TrimSpace(string(body))}
+		}
+
+		return body, nil
+	}
+}
+
+// getNotifierRetryAfter returns how long to wait before retrying a rate limited request, using the Retry-After header or the retry_after field sent by Discord, both in seconds
+func getNotifierRetryAfter(response *http.Response, body []byte) time.Duration {
+	retryAfter, err := strconv.ParseFloat(response.Header.Get("Retry-After"), 64)
+	if err != nil {
+		var errorMessage DiscordErrorMessage
+		if json.Unmarshal(body, &errorMessage) != nil {
+			return 0
+		}
+		retryAfter = errorMessage.RetryAfter
+	}
+
+	return time.Duration(retryAfter * float64(time.Second))
+}
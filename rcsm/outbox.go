@@ -0,0 +1,131 @@
+package rcsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// outboxSequence keeps outbox file names ordered when several events are stored in the same nanosecond
+var outboxSequence int64
+
+// eventOutbox stores undelivered events of a sink on disk, in order, until they can be delivered
+type eventOutbox struct {
+	directory string
+	fileNames []string
+}
+
+// permanentEventError is implemented by errors that happen again when an event is retried, such as a rejected request
+type permanentEventError interface {
+	permanent() bool
+}
+
+// isPermanentEventError returns true if sending an event again would fail the same way
+func isPermanentEventError(err error) bool {
+	permanentError, ok := err.(permanentEventError)
+	return ok && permanentError.permanent()
+}
+
+// isPermanentStatus returns true for HTTP statuses of requests that are rejected again when retried, rate limits and timeouts can be retried
+func isPermanentStatus(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500 && statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests
+}
+
+// newEventOutbox opens an outbox, events left by a previous run are delivered first
+func newEventOutbox(outboxName string) (*eventOutbox, error) {
+	directoryName := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(outboxName)
+	outbox := &eventOutbox{
+		directory: path.Join(EventsOutboxDirectory, directoryName),
+	}
+
+	err := os.MkdirAll(outbox.directory, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNodes, err := ioutil.ReadDir(outbox.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fileNode := range fileNodes {
+		if !fileNode.IsDir() && strings.HasSuffix(fileNode.Name(), ".json") {
+			outbox.fileNames = append(outbox.fileNames, fileNode.Name())
+		}
+	}
+	sort.Strings(outbox.fileNames)
+
+	return outbox, nil
+}
+
+// isEmpty checks if there are events waiting to be delivered
+func (outbox *eventOutbox) isEmpty() bool {
+	return len(outbox.fileNames) == 0
+}
+
+// push stores an event at the end of the outbox, dropping the oldest events if it's full
+func (outbox *eventOutbox) push(event Event) error {
+	jsonEvent, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("%020d-%010d.json", time.Now().UnixNano(), atomic.AddInt64(&outboxSequence, 1))
+	err = ioutil.WriteFile(path.Join(outbox.directory, fileName), jsonEvent, 0600)
+	if err != nil {
+		return err
+	}
+	outbox.fileNames = append(outbox.fileNames, fileName)
+
+	for EventsOutboxMaxEvents > 0 && int64(len(outbox.fileNames)) > EventsOutboxMaxEvents {
+		log.Printf("Outbox %s is full, dropping its oldest event", outbox.directory)
+		outbox.pop()
+	}
+
+	return nil
+}
+
+// peek reads the oldest event of the outbox
+func (outbox *eventOutbox) peek() (Event, error) {
+	var event Event
+
+	jsonEvent, err := ioutil.ReadFile(path.Join(outbox.directory, outbox.fileNames[0]))
+	if err != nil {
+		return event, err
+	}
+
+	err = json.Unmarshal(jsonEvent, &event)
+	return event, err
+}
+
+// deadLetter moves the oldest event of the outbox to its failed directory, so it can be inspected without blocking the next events
+func (outbox *eventOutbox) deadLetter() {
+	failedDirectory := path.Join(outbox.directory, "failed")
+	err := os.MkdirAll(failedDirectory, 0700)
+	if err == nil {
+		err = os.Rename(path.Join(outbox.directory, outbox.fileNames[0]), path.Join(failedDirectory, outbox.fileNames[0]))
+	}
+	if err != nil {
+		log.Printf("Could not move event to %s, dropping it: %s", failedDirectory, err)
+		outbox.pop()
+		return
+	}
+	outbox.fileNames = outbox.fileNames[1:]
+}
+
+// pop deletes the oldest event of the outbox
+func (outbox *eventOutbox) pop() {
+	err := os.Remove(path.Join(outbox.directory, outbox.fileNames[0]))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Could not delete event from outbox %s: %s", outbox.directory, err)
+	}
+	outbox.fileNames = outbox.fileNames[1:]
+}
@@ -1,13 +1,12 @@
 package rcsm
 
 import (
-	"archive/tar"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
-	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -22,108 +21,172 @@ var (
 	s3ClientLock sync.Mutex
 )
 
-// UpdateTemplate downloads the most recent template from S3 and tries to update server files
-func UpdateTemplate(serverName string) {
-	if !templateExists(serverName) {
-		TriggerLogEvent("warn", serverName, fmt.Sprintf("No template found on s3://%s", S3Bucket))
-	} else {
-		downloadTemplate(serverName)
+// UpdateTemplate downloads the most recent template and tries to update server files, the server must not be started if it fails
+func UpdateTemplate(serverName string) error {
+	templateKey, templateVersion, found := findTemplate(serverName)
+	if !found {
+		return nil
 	}
+	return downloadTemplate(serverName, templateKey, templateVersion)
 }
 
-func templateExists(serverName string) bool {
-	client, _ := getS3Client()
-	resp, err := client.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(S3Bucket)})
-	if err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Unable to list items in bucket %s", err))
-		return false
-	}
+func downloadTemplate(serverName string, templateKey string, templateVersion string) error {
+	serverPath := path.Join(MinecraftServersDirectory, serverName)
 
-	for _, item := range resp.Contents {
-		if *item.Key == fmt.Sprintf("%s.tar", serverName) {
-			return true
+	templateFile, templateLocation, err := downloadTemplateFile(serverName, templateKey)
+	if err != nil {
+		eventType := EventTemplateFailed
+		if _, rejected := err.(templateSignatureError); rejected {
+			eventType = EventTemplateRejected
 		}
+		TriggerEvent(eventType, "severe", serverName, fmt.Sprintf("Unable to download template: %s", err), EventFields{
+			"server":   serverName,
+			"template": templateLocation,
+			"error":    err.Error(),
+		})
+		return err
 	}
-
-	return false
-}
-
-func downloadTemplate(serverName string) {
-	_, downloader := getS3Client()
-
-	s3Bucket := S3Bucket
-	templateFileName := fmt.Sprintf("%s.tar", serverName)
-	s3Location := fmt.Sprintf("s3://%s/%s", s3Bucket, templateFileName)
-	serverPath := path.Join(MinecraftServersDirectory, serverName)
-
-	TriggerLogEvent("debug", serverName, fmt.Sprintf("Downloading template %s", s3Location))
-
-	templateFile, err := ioutil.TempFile("", "rcsm-template")
-	defer templateFile.Close()
 	defer os.Remove(templateFile.Name())
+	defer templateFile.Close()
 
-	_, err = downloader.Download(templateFile,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s3Bucket),
-			Key:    aws.String(templateFileName),
-		})
-	if err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Unable to download template: %s", err))
-		return
+	// Variables are read from the config of the server before the template is applied
+	var variables map[string]string
+	if TemplateVariablesEnabled {
+		variables, err = getTemplateVariables(serverName, serverPath)
+		if err != nil {
+			err = fmt.Errorf("Could not load template variables: %s", err)
+			TriggerEvent(EventTemplateFailed, "severe", serverName, fmt.Sprintf("Unable to apply template %s: %s", templateLocation, err), EventFields{
+				"server":   serverName,
+				"template": templateLocation,
+				"error":    err.Error(),
+			})
+			return err
+		}
 	}
 
-	archive := tar.NewReader(templateFile)
-	for {
-		header, err := archive.Next()
-		if err == io.EOF {
-			break // End of archive
+	renderedFiles := 0
+
+	err = walkTemplateArchive(templateFile, func(entry templateEntry) error {
+		entryName, render := entry.Name, false
+		if !entry.IsDir {
+			entryName, render = getTemplateOutputName(entry.Name)
 		}
+
+		outputFile, err := getTemplateEntryPath(serverPath, entryName)
 		if err != nil {
-			TriggerLogEvent("severe", serverName, fmt.Sprintf("Error while reading template %s: %s", s3Location, err))
-			continue
+			return err
 		}
 
-		pathToDelete := path.Join(serverPath, header.Name)
-
-		trimSet := "/."
-		if strings.Trim(pathToDelete, trimSet) == strings.Trim(serverPath, trimSet) {
+		if outputFile == path.Clean(serverPath) {
 			// Don't delete the server directory
-			continue
+			return nil
 		}
 
-		err = os.RemoveAll(pathToDelete)
-		if err != nil {
-			TriggerLogEvent("severe", serverName, fmt.Sprintf("Could not delete previous config: %s", err))
-			continue
+		// Files are rendered before the previous ones are deleted, so a file is never left with its variables
+		contents := entry.Reader
+		if render {
+			rawContents, err := ioutil.ReadAll(entry.Reader)
+			if err != nil {
+				return fmt.Errorf("Could not read %s from template: %s", entryName, err)
+			}
+
+			rendered, err := renderTemplateVariables(entryName, rawContents, variables)
+			if err != nil {
+				return fmt.Errorf("Could not substitute variables in %s: %s", entryName, err)
+			}
+			contents = bytes.NewReader(rendered)
+			renderedFiles++
 		}
 
-		outputFile := path.Join(serverPath, header.Name)
+		err = os.RemoveAll(outputFile)
+		if err != nil {
+			return fmt.Errorf("Could not delete previous config: %s", err)
+		}
 
 		directory, _ := path.Split(outputFile)
+		if entry.IsDir {
+			directory = outputFile
+		}
 
 		err = os.MkdirAll(directory, os.ModePerm)
 		if err != nil {
-			TriggerLogEvent("severe", serverName, fmt.Sprintf("Could not create directory: %s", err))
-			continue
+			return fmt.Errorf("Could not create directory: %s", err)
 		}
 
-		if header.Typeflag == tar.TypeReg {
-			file, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY, os.ModePerm)
-
+		if !entry.IsDir {
+			file, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
 			if err != nil {
-				TriggerLogEvent("severe", serverName, fmt.Sprintf("Could not open file to copy from template: %s", err))
-				continue
+				return fmt.Errorf("Could not open file to copy from template: %s", err)
 			}
 			defer file.Close()
 
-			_, err = io.Copy(file, archive)
+			_, err = io.Copy(file, contents)
 			if err != nil {
-				TriggerLogEvent("severe", serverName, fmt.Sprintf("Could not copy file from template: %s", err))
-				continue
+				return fmt.Errorf("Could not copy file from template: %s", err)
 			}
 		}
+
+		return nil
+	})
+	if err != nil {
+		// The template is partially extracted, it's not reported as applied
+		TriggerEvent(EventTemplateFailed, "severe", serverName, fmt.Sprintf("Error while reading template %s: %s", templateLocation, err), EventFields{
+			"server":   serverName,
+			"template": templateLocation,
+			"error":    err.Error(),
+		})
+		return err
+	}
+
+	if renderedFiles > 0 {
+		TriggerLogEvent("debug", serverName, fmt.Sprintf("Substituted variables in %d template file(s)", renderedFiles))
+	}
+
+	updateServerState(serverName, func(state *serverState) {
+		state.TemplateVersion = templateVersion
+	})
+
+	TriggerEvent(EventTemplateApplied, "info", serverName, fmt.Sprintf("Template applied from %s", templateLocation), EventFields{
+		"server":   serverName,
+		"template": templateLocation,
+	})
+
+	return nil
+}
+
+// downloadTemplateFile downloads the template of a server to a temporary file, the caller has to remove it
+func downloadTemplateFile(serverName string, templateKey string) (*os.File, string, error) {
+	source := getTemplateSource()
+	templateLocation := source.Location(templateKey)
+
+	TriggerLogEvent("debug", serverName, fmt.Sprintf("Downloading template %s", templateLocation))
+
+	templateFile, err := ioutil.TempFile("", "rcsm-template")
+	if err != nil {
+		return nil, templateLocation, err
+	}
+
+	err = source.Download(templateKey, templateFile)
+	if err == nil {
+		_, err = templateFile.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		templateFile.Close()
+		os.Remove(templateFile.Name())
+		return nil, templateLocation, err
+	}
+
+	if TemplateSignatureEnabled {
+		err = verifyTemplateSignature(templateKey, templateFile)
+		if err != nil {
+			templateFile.Close()
+			os.Remove(templateFile.Name())
+			return nil, templateLocation, templateSignatureError{fmt.Errorf("Refusing template %s, signature verification failed: %s", templateLocation, err)}
+		}
+		TriggerLogEvent("debug", serverName, fmt.Sprintf("Verified signature of template %s", templateLocation))
 	}
-	TriggerLogEvent("info", serverName, fmt.Sprintf("Template applied from %s", s3Location))
+
+	return templateFile, templateLocation, nil
 }
 
 func getS3Client() (*s3.S3, *s3manager.Downloader) {
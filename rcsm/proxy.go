@@ -0,0 +1,233 @@
+package rcsm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxySchema is the schema of messages exchanged with proxies
+const proxySchema = "rcsm.proxy"
+
+// ProxyMessage defines the format of the messages sent to register servers on proxies
+type ProxyMessage struct {
+	Schema     string `json:"schema"`
+	Version    int    `json:"version"`
+	Action     string `json:"action"`
+	Instance   string `json:"instance,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Address    string `json:"address,omitempty"`
+	Port       int64  `json:"port,omitempty"`
+	Restricted bool   `json:"restricted"`
+	Timestamp  string `json:"timestamp,omitempty"`
+}
+
+var (
+	// proxyServers keeps the servers currently registered, they are sent again when a proxy asks for a sync
+	proxyServers     = make(map[string]ProxyMessage)
+	proxyServersLock sync.Mutex
+)
+
+// StartProxyRegistration listens for proxies asking for the list of servers, such as after they restarted
+func StartProxyRegistration() {
+	if Bus == nil {
+		return
+	}
+
+	err := Bus.Subscribe(ProxyRegistrationChannel, func(channel string, payload string) {
+		var message ProxyMessage
+		err := json.Unmarshal([]byte(payload), &message)
+		if err != nil || message.Schema != proxySchema || message.Action != "sync" {
+			// Register and unregister messages of rcsm instances are received too
+			return
+		}
+
+		proxyServersLock.Lock()
+		messages := make([]ProxyMessage, 0, len(proxyServers))
+		for _, registerMessage := range proxyServers {
+			messages = append(messages, registerMessage)
+		}
+		proxyServersLock.Unlock()
+
+		for _, registerMessage := range messages {
+			publishProxyMessage(registerMessage)
+		}
+	})
+	if err != nil {
+		TriggerLogEvent("severe", "proxy", fmt.Sprintf("Could not listen for proxy sync requests: %s", err))
+	}
+}
+
+// isProxyEnabled returns true if servers should be registered on a proxy, using the message bus or the local proxy config
+func isProxyEnabled() bool {
+	return (ProxyRegistrationEnabled && isMessageBusEnabled()) || ProxyConfigType != ""
+}
+
+// shouldRegisterOnReady returns true if servers are registered when their console says they are ready instead of when they start
+func shouldRegisterOnReady() bool {
+	return ProxyRegisterOnReady && ConsoleParsingEnabled
+}
+
+// isProxyBackend returns false for servers that must not be registered, such as the proxy itself
+func isProxyBackend(server MinecraftServer) bool {
+	return !server.ProxyExcluded && server.name != ProxyReloadServer
+}
+
+// proxyServerStarted registers a server that was started, unless it's registered once ready
+func proxyServerStarted(server MinecraftServer, newSession bool) {
+	if newSession && shouldRegisterOnReady() {
+		return
+	}
+	registerProxyServer(server)
+}
+
+// proxyServerReady registers a server once its console says it's ready
+func proxyServerReady(serverName string) {
+	if !isProxyEnabled() || !shouldRegisterOnReady() {
+		return
+	}
+
+	minecraftServersLock.Lock()
+	server, found := minecraftServers[serverName]
+	minecraftServersLock.Unlock()
+
+	if found && server.running {
+		registerProxyServer(server)
+	}
+}
+
+// registerProxyServer adds a server to the proxies
+func registerProxyServer(server MinecraftServer) {
+	if !isProxyEnabled() || !isProxyBackend(server) {
+		return
+	}
+
+	port := getProxyServerPort(server)
+	if port == 0 {
+		TriggerLogEvent("warn", server.name, "Not registering the server on the proxy, its port is unknown, set port in its rcsm_config.json")
+		return
+	}
+
+	message := ProxyMessage{
+		Schema:     proxySchema,
+		Version:    redisSchemaVersion,
+		Action:     "register",
+		Instance:   InstanceName,
+		Name:       server.name,
+		Address:    getProxyAddress(false),
+		Port:       port,
+		Restricted: server.ProxyRestricted,
+	}
+
+	proxyServersLock.Lock()
+	proxyServers[server.name] = message
+	proxyServersLock.Unlock()
+
+	if ProxyRegistrationEnabled && isMessageBusEnabled() {
+		publishProxyMessage(message)
+	}
+	if ProxyConfigType != "" {
+		updateProxyConfig(server.name, fmt.Sprintf("%s:%d", getProxyAddress(true), port), server.ProxyRestricted)
+	}
+
+	TriggerLogEvent("debug", server.name, "Registered the server on the proxy")
+}
+
+// unregisterProxyServer removes a server from the proxies, players are sent elsewhere before it stops
+func unregisterProxyServer(server MinecraftServer) {
+	if !isProxyEnabled() || !isProxyBackend(server) {
+		return
+	}
+
+	proxyServersLock.Lock()
+	_, registered := proxyServers[server.name]
+	delete(proxyServers, server.name)
+	proxyServersLock.Unlock()
+
+	if !registered {
+		return
+	}
+
+	if ProxyRegistrationEnabled && isMessageBusEnabled() {
+		publishProxyMessage(ProxyMessage{
+			Schema:   proxySchema,
+			Version:  redisSchemaVersion,
+			Action:   "unregister",
+			Instance: InstanceName,
+			Name:     server.name,
+		})
+	}
+	if ProxyConfigType != "" {
+		updateProxyConfig(server.name, "", false)
+	}
+
+	TriggerLogEvent("debug", server.name, "Unregistered the server from the proxy")
+}
+
+// publishProxyMessage sends a message on the proxy channel, proxies can ask for a sync if they missed it
+func publishProxyMessage(message ProxyMessage) {
+	if Bus == nil || !Bus.Available() {
+		TriggerLogEvent("warn", "proxy", fmt.Sprintf("Could not %s %s on the proxy, the message bus is unavailable", message.Action, message.Name))
+		return
+	}
+
+	message.Timestamp = time.Now().Format(time.RFC3339)
+	payload, err := json.Marshal(message)
+	if err != nil {
+		TriggerLogEvent("warn", "proxy", fmt.Sprintf("Could not serialize proxy message: %s", err))
+		return
+	}
+
+	err = Bus.Publish(ProxyRegistrationChannel, string(payload))
+	if err != nil {
+		TriggerLogEvent("warn", "proxy", fmt.Sprintf("Could not %s %s on the proxy: %s", message.Action, message.Name, err))
+	}
+}
+
+// getProxyAddress returns the host proxies should connect to, local proxies use the loopback address by default
+func getProxyAddress(local bool) string {
+	if ProxyAdvertisedHost != "" {
+		return ProxyAdvertisedHost
+	}
+	if local {
+		return "127.0.0.1"
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "127.0.0.1"
+	}
+	return hostname
+}
+
+// getProxyServerPort returns the port of a server, from its rcsm_config.json or its server.properties
+func getProxyServerPort(server MinecraftServer) int64 {
+	if server.Port != 0 {
+		return server.Port
+	}
+
+	file, err := os.Open(path.Join(server.fullPath, "server.properties"))
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "server-port=") {
+			port, err := strconv.ParseInt(strings.TrimPrefix(line, "server-port="), 10, 64)
+			if err == nil {
+				return port
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,227 @@
+package rcsm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	// proxyConfigLock prevents servers starting at the same time from overwriting each other in the proxy config
+	proxyConfigLock sync.Mutex
+
+	tomlTableRegex        = regexp.MustCompile(`^\s*\[[^\]]+\]\s*(#.*)?$`)
+	velocityServerRegex   = regexp.MustCompile(`^\s*"?([^"=\s]+)"?\s*=\s*"[^"]*"\s*(#.*)?$`)
+	yamlTopLevelKeyRegex  = regexp.MustCompile(`^[^\s#]`)
+	bungeeCordServerRegex = regexp.MustCompile(`^(\s+)['"]?([^'":#\s]+)['"]?:\s*$`)
+	tomlBareKeyRegex      = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// updateProxyConfig sets the address of a server in the config of a local proxy, an empty address removes it
+func updateProxyConfig(serverName string, address string, restricted bool) {
+	proxyConfigLock.Lock()
+	defer proxyConfigLock.Unlock()
+
+	content, err := ioutil.ReadFile(ProxyConfigFile)
+	if err != nil {
+		TriggerLogEvent("severe", "proxy", fmt.Sprintf("Could not read the proxy config: %s", err))
+		return
+	}
+	lines := strings.Split(string(content), "\n")
+
+	switch strings.ToLower(ProxyConfigType) {
+	case "velocity":
+		lines, err = setVelocityServer(lines, serverName, address)
+	case "bungeecord":
+		lines, err = setBungeeCordServer(lines, serverName, address, restricted)
+	default:
+		err = fmt.Errorf("unknown PROXY_CONFIG_TYPE %s, it should be velocity or bungeecord", ProxyConfigType)
+	}
+	if err != nil {
+		TriggerLogEvent("severe", "proxy", fmt.Sprintf("Could not update the proxy config: %s", err))
+		return
+	}
+
+	updatedContent := strings.Join(lines, "\n")
+	if updatedContent == string(content) {
+		return
+	}
+
+	// The proxy could read a partially written file, so a new file replaces the config
+	fileInfo, err := os.Stat(ProxyConfigFile)
+	if err == nil {
+		err = ioutil.WriteFile(ProxyConfigFile+".rcsm", []byte(updatedContent), fileInfo.Mode())
+	}
+	if err == nil {
+		err = os.Rename(ProxyConfigFile+".rcsm", ProxyConfigFile)
+	}
+	if err != nil {
+		TriggerLogEvent("severe", "proxy", fmt.Sprintf("Could not write the proxy config: %s", err))
+		return
+	}
+
+	reloadProxy()
+}
+
+// reloadProxy runs the reload command in the console of the proxy, if it's managed by rcsm
+func reloadProxy() {
+	if ProxyReloadServer == "" || ProxyReloadCommand == "" || !SessionExists(ProxyReloadServer) {
+		return
+	}
+
+	err := SessionRunCommand(ProxyReloadServer, ProxyReloadCommand)
+	if err != nil {
+		TriggerLogEvent("warn", "proxy", fmt.Sprintf("Could not reload the proxy: %s", err))
+	}
+}
+
+// setVelocityServer sets a server in the [servers] table of velocity.toml, the try list and other tables are left untouched
+func setVelocityServer(lines []string, serverName string, address string) ([]string, error) {
+	start := -1
+	end := len(lines)
+	for i, line := range lines {
+		if !tomlTableRegex.MatchString(line) {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		if strings.TrimSpace(strings.SplitN(line, "#", 2)[0]) == "[servers]" {
+			start = i
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no [servers] table found in %s", ProxyConfigFile)
+	}
+
+	key := serverName
+	if !tomlBareKeyRegex.MatchString(key) {
+		key = fmt.Sprintf("%q", key)
+	}
+	serverLine := fmt.Sprintf("%s = \"%s\"", key, address)
+	insertAt := start + 1
+	for i := start + 1; i < end; i++ {
+		match := velocityServerRegex.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+		if match[1] == serverName {
+			if address == "" {
+				return append(lines[:i:i], lines[i+1:]...), nil
+			}
+			lines[i] = serverLine
+			return lines, nil
+		}
+		insertAt = i + 1
+	}
+
+	if address == "" {
+		return lines, nil
+	}
+
+	// New servers are added after the existing ones, before the try list
+	return append(lines[:insertAt:insertAt], append([]string{serverLine}, lines[insertAt:]...)...), nil
+}
+
+// setBungeeCordServer sets a server in the servers section of the BungeeCord config.yml, the motd of existing servers is kept
+func setBungeeCordServer(lines []string, serverName string, address string, restricted bool) ([]string, error) {
+	start := -1
+	end := len(lines)
+	for i, line := range lines {
+		if !yamlTopLevelKeyRegex.MatchString(line) {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		if strings.HasPrefix(line, "servers:") {
+			start = i
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no servers section found in %s", ProxyConfigFile)
+	}
+
+	// An empty section is written as servers: {}
+	lines[start] = "servers:"
+
+	// Servers are indented like the first line of the section
+	indent := "  "
+	for _, line := range lines[start+1 : end] {
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine != "" && !strings.HasPrefix(trimmedLine, "#") {
+			indent = line[:len(line)-len(strings.TrimLeft(line, " "))]
+			break
+		}
+	}
+
+	serverStart := -1
+	serverEnd := end
+	for i := start + 1; i < end; i++ {
+		match := bungeeCordServerRegex.FindStringSubmatch(lines[i])
+		if match == nil || match[1] != indent {
+			continue
+		}
+		if serverStart >= 0 {
+			serverEnd = i
+			break
+		}
+		if match[2] == serverName {
+			serverStart = i
+		}
+	}
+
+	if serverStart < 0 {
+		if address == "" {
+			return lines, nil
+		}
+
+		// Blank lines at the end of the section are kept after the new server
+		insertAt := end
+		for insertAt > start+1 && strings.TrimSpace(lines[insertAt-1]) == "" {
+			insertAt--
+		}
+		serverLines := []string{
+			fmt.Sprintf("%s%s:", indent, serverName),
+			fmt.Sprintf("%s%smotd: '%s'", indent, indent, serverName),
+			fmt.Sprintf("%s%saddress: %s", indent, indent, address),
+			fmt.Sprintf("%s%srestricted: %t", indent, indent, restricted),
+		}
+		return append(lines[:insertAt:insertAt], append(serverLines, lines[insertAt:]...)...), nil
+	}
+
+	if address == "" {
+		return append(lines[:serverStart:serverStart], lines[serverEnd:]...), nil
+	}
+
+	serverLines := []string{lines[serverStart]}
+	propertyIndent := indent + indent
+	for _, line := range lines[serverStart+1 : serverEnd] {
+		property := strings.TrimSpace(line)
+		if strings.HasPrefix(property, "address:") || strings.HasPrefix(property, "restricted:") {
+			continue
+		}
+		if property != "" && !strings.HasPrefix(property, "#") {
+			propertyIndent = line[:len(line)-len(strings.TrimLeft(line, " "))]
+		}
+		serverLines = append(serverLines, line)
+	}
+
+	// Properties are added before the blank lines separating the next server
+	insertAt := len(serverLines)
+	for insertAt > 1 && strings.TrimSpace(serverLines[insertAt-1]) == "" {
+		insertAt--
+	}
+	properties := []string{
+		fmt.Sprintf("%saddress: %s", propertyIndent, address),
+		fmt.Sprintf("%srestricted: %t", propertyIndent, restricted),
+	}
+	serverLines = append(serverLines[:insertAt:insertAt], append(properties, serverLines[insertAt:]...)...)
+
+	return append(lines[:serverStart:serverStart], append(serverLines, lines[serverEnd:]...)...), nil
+}
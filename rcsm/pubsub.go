@@ -2,6 +2,8 @@ package rcsm
 
 import (
 	"context"
+	"net"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -14,20 +16,55 @@ type ChannelListener struct {
 	PubSub   *redis.PubSub
 	Channel  string
 	Callback callbackFunc
+	lock     sync.Mutex
 }
 
-// StartRedisListener starts a listener and returns a ChannelListener instance
+var (
+	redisListeners     []*ChannelListener
+	redisListenersLock sync.Mutex
+)
+
+// StartRedisListener starts a listener and returns a ChannelListener instance, it's subscribed again after reconnections
 func StartRedisListener(channel string, callback callbackFunc) (*ChannelListener, error) {
-	listener := ChannelListener{
+	listener := &ChannelListener{
 		PubSub:   RedisClient.Subscribe(context.TODO(), channel),
 		Channel:  channel,
 		Callback: callback,
 	}
 
+	redisListenersLock.Lock()
+	redisListeners = append(redisListeners, listener)
+	redisListenersLock.Unlock()
+
 	// Listen for messages
 	go listener.listen()
 
-	return &listener, nil
+	return listener, nil
+}
+
+// resubscribeRedisListeners subscribes listeners again, used when Redis is back
+func resubscribeRedisListeners() {
+	redisListenersLock.Lock()
+	defer redisListenersLock.Unlock()
+
+	for _, listener := range redisListeners {
+		listener.resubscribe()
+	}
+}
+
+func (listener *ChannelListener) resubscribe() {
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	listener.PubSub.Close()
+	listener.PubSub = RedisClient.Subscribe(context.TODO(), listener.Channel)
+}
+
+func (listener *ChannelListener) getPubSub() *redis.PubSub {
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	return listener.PubSub
 }
 
 func (listener *ChannelListener) listen() error {
@@ -35,10 +72,19 @@ func (listener *ChannelListener) listen() error {
 	var payload string
 
 	for {
-		msg, err := listener.PubSub.ReceiveTimeout(context.TODO(), time.Second)
-		if err != nil {
+		pubSub := listener.getPubSub()
+		msg, err := pubSub.ReceiveTimeout(context.TODO(), time.Second)
+		if netError, ok := err.(net.Error); ok && netError.Timeout() {
 			// Timeout, ignore
 			continue
+		} else if err != nil && pubSub != listener.getPubSub() {
+			// The listener was subscribed again, the previous subscription was closed
+			continue
+		} else if err != nil {
+			// The connection is checked again with a backoff, the listener is subscribed again once it's back
+			setRedisUnavailable(err)
+			time.Sleep(time.Second)
+			continue
 		}
 
 		channel = ""
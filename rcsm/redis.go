@@ -2,30 +2,202 @@ package rcsm
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-// RedisClient is the client instance
-var RedisClient *redis.Client
+// RedisClient is the client instance, a single node, Sentinel or Cluster client depending on RedisMode
+var RedisClient redis.UniversalClient
 
-// RedisConnect actually RedisConnects the redis client if enabled
+var (
+	// redisStateKnown is false until the first connection attempt, so the initial state always triggers an event
+	redisStateKnown bool
+	redisStateLock  sync.Mutex
+)
+
+// RedisConnect actually RedisConnects the redis client if enabled, and keeps checking the connection afterwards
 func RedisConnect() {
 	TriggerLogEvent("debug", "redis", fmt.Sprintf("RedisConnecting to %s", RedisHost))
 
-	RedisClient = redis.NewClient(&redis.Options{
-		Network:  "tcp",
-		Addr:     RedisHost,
-		Password: RedisPassword,
-		DB:       int(RedisDatabase),
-	})
+	client, err := newRedisClient()
+	if err != nil {
+		TriggerLogEvent("fatal", "redis", fmt.Sprintf("Invalid Redis config: %s", err))
+		os.Exit(1)
+	}
+	RedisClient = client
+
+	checkRedisConnection()
+
+	go monitorRedisConnection()
+}
+
+// newRedisClient creates the client matching RedisMode
+func newRedisClient() (redis.UniversalClient, error) {
+	tlsConfig, err := getRedisTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := splitConfigList(RedisHost)
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("REDIS_HOST is empty")
+	}
+
+	switch strings.ToLower(RedisMode) {
+	case "standalone":
+		return redis.NewClient(&redis.Options{
+			Network:   "tcp",
+			Addr:      addresses[0],
+			Username:  RedisUsername,
+			Password:  RedisPassword,
+			DB:        int(RedisDatabase),
+			TLSConfig: tlsConfig,
+		}), nil
+	case "sentinel":
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       RedisSentinelMaster,
+			SentinelAddrs:    addresses,
+			SentinelPassword: RedisSentinelPassword,
+			Username:         RedisUsername,
+			Password:         RedisPassword,
+			DB:               int(RedisDatabase),
+			TLSConfig:        tlsConfig,
+		}), nil
+	case "cluster":
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     addresses,
+			Username:  RedisUsername,
+			Password:  RedisPassword,
+			TLSConfig: tlsConfig,
+		}), nil
+	}
+
+	return nil, fmt.Errorf("unknown REDIS_MODE %s, it should be standalone, sentinel or cluster", RedisMode)
+}
+
+// getRedisTLSConfig creates the TLS config of the Redis client, nil if TLS is disabled
+func getRedisTLSConfig() (*tls.Config, error) {
+	if !RedisTLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         RedisTLSServerName,
+		InsecureSkipVerify: RedisTLSSkipVerify,
+	}
+
+	if RedisTLSCAFile != "" {
+		caCertificates, err := ioutil.ReadFile(RedisTLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCertificates) {
+			return nil, fmt.Errorf("no certificate found in %s", RedisTLSCAFile)
+		}
+	}
+
+	if RedisTLSCertFile != "" || RedisTLSKeyFile != "" {
+		clientCertificate, err := tls.LoadX509KeyPair(RedisTLSCertFile, RedisTLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCertificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// monitorRedisConnection pings Redis regularly, and retries with an exponential backoff while it's unavailable
+func monitorRedisConnection() {
+	retryDelay := time.Second
+	maxRetryDelay := time.Duration(RedisReconnectMaxDelaySec) * time.Second
+
+	for {
+		if isRedisAvailable() {
+			retryDelay = time.Second
+			time.Sleep(time.Duration(RedisHealthCheckIntervalSec) * time.Second)
+		} else {
+			time.Sleep(retryDelay)
+			retryDelay *= 2
+			if retryDelay > maxRetryDelay {
+				retryDelay = maxRetryDelay
+			}
+		}
+
+		checkRedisConnection()
+	}
+}
+
+// checkRedisConnection pings Redis and updates redisAvailable
+func checkRedisConnection() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	response := RedisClient.Ping(context.TODO())
-	if response.String() != "ping: PONG" {
-		TriggerLogEvent("severe", "redis", fmt.Sprintf("Error while RedisConnecting: `%s`", response.String()))
-	} else {
-		TriggerLogEvent("debug", "redis", "RedisConnected")
-		RedisAvailable = true
+	err := RedisClient.Ping(ctx).Err()
+	if err != nil {
+		setRedisUnavailable(err)
+		return
 	}
+
+	if setRedisAvailable() {
+		resubscribeRedisListeners()
+	}
+}
+
+// isRedisAvailable returns if Redis answered the last health check
+func isRedisAvailable() bool {
+	redisStateLock.Lock()
+	defer redisStateLock.Unlock()
+
+	return redisAvailable
+}
+
+// setRedisAvailable marks Redis as available and returns true if it was reconnected after being unavailable
+func setRedisAvailable() bool {
+	redisStateLock.Lock()
+	wasKnown := redisStateKnown
+	wasAvailable := redisAvailable
+	redisStateKnown = true
+	redisAvailable = true
+	redisStateLock.Unlock()
+
+	if wasAvailable {
+		return false
+	}
+
+	if !wasKnown {
+		TriggerEvent(EventRedisConnected, "debug", "redis", "RedisConnected", nil)
+		return false
+	}
+
+	TriggerEvent(EventRedisConnected, "info", "redis", "Reconnected to Redis", nil)
+	return true
+}
+
+// setRedisUnavailable marks Redis as unavailable, the event is only triggered when the state changes
+func setRedisUnavailable(err error) {
+	redisStateLock.Lock()
+	wasKnown := redisStateKnown
+	wasAvailable := redisAvailable
+	redisStateKnown = true
+	redisAvailable = false
+	redisStateLock.Unlock()
+
+	if wasKnown && !wasAvailable {
+		return
+	}
+
+	TriggerEvent(EventRedisUnavailable, "severe", "redis", fmt.Sprintf("Redis is unavailable, retrying in the background: %s", err), EventFields{
+		"error": err.Error(),
+	})
 }
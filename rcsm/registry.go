@@ -0,0 +1,174 @@
+package rcsm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// registryStartedAt is when rcsm started, published in the instance hash
+var registryStartedAt = time.Now()
+
+// StartRedisRegistry starts a task publishing the state of this instance and its servers in Redis hashes with a TTL
+func StartRedisRegistry() {
+	if RedisRegistryPruneAfterSec > 0 {
+		RegisterGlobalJob("registry_prune", time.Hour, pruneRedisRegistry)
+	}
+
+	ticker := time.NewTicker(time.Duration(RedisRegistryIntervalSec) * time.Second)
+	go func() {
+		for {
+			if isRedisAvailable() {
+				err := publishRedisRegistry()
+				if err != nil {
+					TriggerLogEvent("warn", "redis", fmt.Sprintf("Could not publish the registry: %s", err))
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ClearRedisRegistry removes this instance from the registry, so it's not mistaken for a dead instance
+func ClearRedisRegistry() {
+	if !isRedisAvailable() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, server := range getServersSnapshot() {
+			pipe.Del(ctx, getRegistryServerKey(server.name))
+		}
+		pipe.Del(ctx, getRegistryInstanceKey())
+		pipe.ZRem(ctx, getRegistryInstancesKey(), InstanceName)
+		return nil
+	})
+	if err != nil {
+		TriggerLogEvent("warn", "redis", fmt.Sprintf("Could not clear the registry: %s", err))
+	}
+}
+
+// pruneRedisRegistry removes the instances that stopped sending heartbeats a long time ago, it's run by the leader only
+func pruneRedisRegistry() {
+	if !isRedisAvailable() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	maxHeartbeat := time.Now().Add(-time.Duration(RedisRegistryPruneAfterSec) * time.Second).Unix()
+	pruned, err := RedisClient.ZRemRangeByScore(ctx, getRegistryInstancesKey(), "-inf", fmt.Sprintf("%d", maxHeartbeat)).Result()
+	if err != nil {
+		TriggerLogEvent("warn", "redis", fmt.Sprintf("Could not prune the registry: %s", err))
+	} else if pruned > 0 {
+		TriggerLogEvent("info", "redis", fmt.Sprintf("Pruned %d instance(s) without heartbeat from the registry", pruned))
+	}
+}
+
+// publishRedisRegistry writes the instance and server hashes, they expire if rcsm stops sending heartbeats
+func publishRedisRegistry() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	ttl := time.Duration(RedisRegistryTTLSec) * time.Second
+	servers := getServersSnapshot()
+
+	serverNames := make([]string, 0, len(servers))
+	for _, server := range servers {
+		serverNames = append(serverNames, server.name)
+	}
+
+	hostname, _ := os.Hostname()
+
+	_, err := RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		instanceKey := getRegistryInstanceKey()
+		pipe.HSet(ctx, instanceKey, map[string]interface{}{
+			"instance":     InstanceName,
+			"hostname":     hostname,
+			"rcsm_version": Version,
+			"started_at":   registryStartedAt.Unix(),
+			"heartbeat":    now.Unix(),
+			"servers":      strings.Join(serverNames, ","),
+			"leader":       IsLeader(),
+		})
+		pipe.Expire(ctx, instanceKey, ttl)
+
+		// The sorted set keeps the last heartbeat of instances after their hash expired
+		pipe.ZAdd(ctx, getRegistryInstancesKey(), &redis.Z{Score: float64(now.Unix()), Member: InstanceName})
+
+		for _, server := range servers {
+			serverKey := getRegistryServerKey(server.name)
+			pipe.HSet(ctx, serverKey, getRegistryServerFields(server, now))
+			pipe.Expire(ctx, serverKey, ttl)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+func getRegistryServerFields(server MinecraftServer, now time.Time) map[string]interface{} {
+	state := "stopped"
+	if server.crashed {
+		state = "crashed"
+	} else if server.running {
+		state = "running"
+	}
+
+	// The configured version may not be applied yet, such as when the template could not be downloaded
+	var templateVersion string
+	if state, found := getServerState(server.name); found {
+		templateVersion = state.TemplateVersion
+	}
+
+	// Times are Unix timestamps, 0 when unknown
+	var startedAt, uptime, lastBackup int64
+	if server.running && !server.startedAt.IsZero() {
+		startedAt = server.startedAt.Unix()
+		uptime = int64(now.Sub(server.startedAt).Seconds())
+	}
+	if lastBackupTime, found := getLastBackup(server.name); found {
+		lastBackup = lastBackupTime.Unix()
+	}
+
+	return map[string]interface{}{
+		"instance":         InstanceName,
+		"server":           server.name,
+		"state":            state,
+		"running":          server.running,
+		"crashed":          server.crashed,
+		"restart_tries":    server.restartTries,
+		"started_at":       startedAt,
+		"uptime":           uptime,
+		"port":             server.Port,
+		"template_version": templateVersion,
+		"last_backup":      lastBackup,
+		"rcsm_version":     Version,
+		"heartbeat":        now.Unix(),
+	}
+}
+
+// getRegistryInstancesKey returns the sorted set of instances scored by their last heartbeat
+func getRegistryInstancesKey() string {
+	return RedisRegistryPrefix + ":instances"
+}
+
+// getRegistryInstanceKey returns the hash of this instance, such as rcsm:registry:instance:survival
+func getRegistryInstanceKey() string {
+	return RedisRegistryPrefix + ":instance:" + InstanceName
+}
+
+// getRegistryServerKey returns the hash of a server, such as rcsm:registry:server:survival:lobby
+func getRegistryServerKey(serverName string) string {
+	return RedisRegistryPrefix + ":server:" + InstanceName + ":" + serverName
+}
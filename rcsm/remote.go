@@ -2,27 +2,144 @@ package rcsm
 
 import (
 	"encoding/json"
+	"fmt"
+	"path"
+	"time"
 )
 
 // RedisCommand defines the format of a redis command
 type RedisCommand struct {
-	Target  string `json:"target"`
-	Action  string `json:"action"`
-	Content string `json:"content"`
+	// Schema and Version are optional for commands, they are checked if set
+	Schema   string           `json:"schema,omitempty"`
+	Version  int              `json:"version,omitempty"`
+	Target   string           `json:"target"`
+	Action   string           `json:"action"`
+	Content  string           `json:"content"`
+	Instance InstanceSelector `json:"instance,omitempty"`
+	// KeyID, Timestamp, Nonce and Signature are used to authenticate commands when RedisCommandSignaturesEnabled is set
+	KeyID     string `json:"key_id,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
-// ListenForRedisCommands initializes the listener to listen for redis commands
+// InstanceSelector defines the instances a command is for, as a name, a glob pattern or a list of them, empty matches every instance
+type InstanceSelector []string
+
+// UnmarshalJSON accepts a single instance or a list of instances
+func (selector *InstanceSelector) UnmarshalJSON(data []byte) error {
+	var instance string
+	if json.Unmarshal(data, &instance) == nil {
+		*selector = InstanceSelector{instance}
+		return nil
+	}
+
+	var instances []string
+	err := json.Unmarshal(data, &instances)
+	if err != nil {
+		return err
+	}
+	*selector = instances
+
+	return nil
+}
+
+func (selector InstanceSelector) matches(instanceName string) bool {
+	if len(selector) == 0 {
+		return true
+	}
+
+	for _, pattern := range selector {
+		matched, err := path.Match(pattern, instanceName)
+		if pattern == instanceName || (err == nil && matched) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListenForRedisCommands initializes the listener to listen for commands on the message bus, on the shared and the instance channels
 func ListenForRedisCommands() {
-	StartRedisListener(RedisPubSubChannel, parseRedisMessage)
+	channels := []string{RedisCommandsChannel}
+	if RedisPubSubCompatEnabled {
+		channels = append(channels, RedisPubSubChannel)
+	}
+	channels = append(channels, getInstanceChannels()...)
+
+	for _, channel := range channels {
+		err := Bus.Subscribe(channel, parseRedisMessage)
+		if err != nil {
+			TriggerLogEvent("severe", Bus.Name(), fmt.Sprintf("Could not listen for commands on %s: %s", channel, err))
+		}
+	}
+
+	// Streams are only available with Redis, even if another message bus is used
+	if RedisEnabled && RedisCommandsStreamEnabled {
+		startCommandStreams()
+	}
+}
+
+// getInstanceChannels returns the channels for commands sent only to this instance, such as rcsm:commands:survival
+func getInstanceChannels() []string {
+	channels := []string{RedisCommandsChannel + ":" + InstanceName}
+	if RedisPubSubCompatEnabled {
+		channels = append(channels, RedisPubSubChannel+":"+InstanceName)
+	}
+	return channels
+}
+
+func isInstanceChannel(channel string) bool {
+	if RedisEnabled && RedisCommandsStreamEnabled && channel == getInstanceStream() {
+		return true
+	}
+
+	for _, instanceChannel := range getInstanceChannels() {
+		if channel == instanceChannel {
+			return true
+		}
+	}
+	return false
 }
 
 func parseRedisMessage(channel string, payload string) {
+	parseRedisCommand(channel, payload, getCommandMaxAge())
+}
+
+// parseRedisCommand runs a command, maxAge is how old the timestamp of signed commands can be
+func parseRedisCommand(channel string, payload string, maxAge time.Duration) {
 	var redisCommand RedisCommand
 	err := json.Unmarshal([]byte(payload), &redisCommand)
 	if err != nil {
 		return
 	}
 
+	// Events are also published on the channel in compatibility mode, older versions don't set their schema
+	if redisCommand.Schema == redisEventSchema || redisCommand.Target == "" || redisCommand.Action == "" {
+		return
+	}
+
+	if (redisCommand.Schema != "" && redisCommand.Schema != redisCommandSchema) || redisCommand.Version > redisSchemaVersion {
+		TriggerLogEvent("warn", "redis", fmt.Sprintf("Ignoring command with unsupported schema %s version %d", redisCommand.Schema, redisCommand.Version))
+		return
+	}
+
+	if !redisCommand.Instance.matches(InstanceName) {
+		return
+	}
+
+	if RedisCommandSignaturesEnabled {
+		err = verifyRedisCommand(redisCommand, maxAge)
+		if err != nil {
+			TriggerEvent(EventCommandRejected, "severe", "redis", fmt.Sprintf("Rejected command %s on %s: %s", redisCommand.Action, redisCommand.Target, err), EventFields{
+				"action": redisCommand.Action,
+				"target": redisCommand.Target,
+				"key_id": redisCommand.KeyID,
+				"reason": err.Error(),
+			})
+			return
+		}
+	}
+
 	serverName := redisCommand.Target
 
 	if serverName == "*" {
@@ -35,6 +152,8 @@ func parseRedisMessage(channel string, payload string) {
 			RestartAllServers()
 		case "backup":
 			BackupAllServers()
+		case "template-diff":
+			DiffTemplateAllServers()
 		case "run":
 			RunCommandAllServers(redisCommand.Content)
 		}
@@ -48,9 +167,14 @@ func parseRedisMessage(channel string, payload string) {
 			RestartServer(serverName)
 		case "backup":
 			BackupServer(serverName)
+		case "template-diff":
+			DiffTemplateServer(serverName)
 		case "run":
 			RunCommandServer(serverName, redisCommand.Content)
 		}
+	} else if isInstanceChannel(channel) || len(redisCommand.Instance) > 0 {
+		// Servers can be on another instance unless this one was targeted
+		TriggerLogEvent("warn", "redis", fmt.Sprintf("Received a command for unknown server %s", serverName))
 	}
 
 }
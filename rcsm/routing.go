@@ -0,0 +1,190 @@
+package rcsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// namedEndpointRegex matches endpoints with a name, such as "oncall=https://discord.com/api/webhooks/..."
+var namedEndpointRegex = regexp.MustCompile(`^([A-Za-z0-9_-]+)=(.+)$`)
+
+// eventLevels are the event levels, from the least to the most important
+var eventLevels = []string{"DEBUG", "INFO", "WARN", "SEVERE", "FATAL"}
+
+// eventRoutes are the routing rules loaded from EventRoutesFile
+var eventRoutes []EventRoute
+
+// EventRoute defines a rule sending matching events to specific sinks, empty conditions match every event
+type EventRoute struct {
+	Sinks     []string `json:"sinks"`
+	MinLevel  string   `json:"min_level"`
+	Levels    []string `json:"levels"`
+	Types     []string `json:"types"`
+	Services  []string `json:"services"`
+	Instances []string `json:"instances"`
+}
+
+// loadEventRoutes reads the routing rules from EventRoutesFile
+func loadEventRoutes() error {
+	if EventRoutesFile == "" {
+		return nil
+	}
+
+	jsonBytes, err := ioutil.ReadFile(EventRoutesFile)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(jsonBytes, &eventRoutes)
+	if err != nil {
+		return err
+	}
+
+	// Unknown levels would silently be considered as info
+	for _, route := range eventRoutes {
+		if route.MinLevel != "" && !isEventLevel(route.MinLevel) {
+			return fmt.Errorf("Unknown min_level `%s`", route.MinLevel)
+		}
+		for _, levelPattern := range route.Levels {
+			if !matchesAnyLevel(levelPattern) {
+				return fmt.Errorf("Unknown level `%s` in levels", levelPattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateEventSinkLevels checks that every entry of EventSinkLevels is a sink pattern and a known level
+func validateEventSinkLevels() error {
+	for _, sinkLevel := range splitConfigList(EventSinkLevels) {
+		patternLevel := strings.SplitN(sinkLevel, "=", 2)
+		if len(patternLevel) != 2 {
+			return fmt.Errorf("Invalid entry `%s` in EVENT_SINK_LEVELS, expected sink=level", sinkLevel)
+		}
+		if !isEventLevel(strings.TrimSpace(patternLevel[1])) {
+			return fmt.Errorf("Unknown level `%s` in EVENT_SINK_LEVELS", strings.TrimSpace(patternLevel[1]))
+		}
+	}
+	return nil
+}
+
+// parseNamedEndpoint splits an endpoint with an optional name, unnamed endpoints get a default name
+func parseNamedEndpoint(endpoint string, defaultName string) (string, string) {
+	matches := namedEndpointRegex.FindStringSubmatch(endpoint)
+	if matches == nil {
+		return defaultName, endpoint
+	}
+	return matches[1], matches[2]
+}
+
+// getSinkMinLevel returns the minimum level of events sent to a sink, from EventSinkLevels
+func getSinkMinLevel(sinkName string, defaultLevel string) string {
+	minLevel := defaultLevel
+
+	// Later rules win, so a specific endpoint can be set after its notifier
+	for _, sinkLevel := range splitConfigList(EventSinkLevels) {
+		patternLevel := strings.SplitN(sinkLevel, "=", 2)
+		if len(patternLevel) == 2 && matchesSinkPattern(strings.TrimSpace(patternLevel[0]), sinkName) {
+			minLevel = strings.TrimSpace(patternLevel[1])
+		}
+	}
+
+	return strings.ToUpper(minLevel)
+}
+
+// isEventRouted checks if an event should be sent to a sink, sinks without any route receive every event
+func isEventRouted(sinkName string, event Event) bool {
+	sinkHasRoute := false
+
+	for _, route := range eventRoutes {
+		routeMatchesSink := false
+		for _, sinkPattern := range route.Sinks {
+			if matchesSinkPattern(sinkPattern, sinkName) {
+				routeMatchesSink = true
+				break
+			}
+		}
+		if !routeMatchesSink {
+			continue
+		}
+
+		sinkHasRoute = true
+		if route.matches(event) {
+			return true
+		}
+	}
+
+	return !sinkHasRoute
+}
+
+func (route EventRoute) matches(event Event) bool {
+	if route.MinLevel != "" && levelRank(event.Level) < levelRank(route.MinLevel) {
+		return false
+	}
+
+	return matchesAnyPattern(route.Levels, event.Level) &&
+		matchesAnyPattern(route.Types, string(event.Type)) &&
+		matchesAnyPattern(route.Services, event.Service) &&
+		matchesAnyPattern(route.Instances, event.Instance)
+}
+
+// isEventLevel checks if a level is one of eventLevels, ignoring the case
+func isEventLevel(level string) bool {
+	level = strings.ToUpper(level)
+	for _, eventLevel := range eventLevels {
+		if eventLevel == level {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyLevel checks if a glob pattern of a route matches at least one level
+func matchesAnyLevel(levelPattern string) bool {
+	for _, eventLevel := range eventLevels {
+		if matchesAnyPattern([]string{levelPattern}, eventLevel) {
+			return true
+		}
+	}
+	return false
+}
+
+// levelRank returns the importance of a level, unknown levels are considered as info
+func levelRank(level string) int {
+	level = strings.ToUpper(level)
+	for rank, eventLevel := range eventLevels {
+		if eventLevel == level {
+			return rank
+		}
+	}
+	return 1
+}
+
+// matchesSinkPattern checks if a sink name such as "discord:oncall" matches "discord", "discord:oncall" or "discord:*"
+func matchesSinkPattern(pattern string, sinkName string) bool {
+	if pattern == sinkName || strings.HasPrefix(sinkName, pattern+":") {
+		return true
+	}
+	matched, err := path.Match(pattern, sinkName)
+	return err == nil && matched
+}
+
+// matchesAnyPattern checks if a value matches one of the glob patterns, an empty list matches everything
+func matchesAnyPattern(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+		if err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"sync"
 	"time"
 )
@@ -17,9 +18,16 @@ type MinecraftServer struct {
 	crashed             bool
 	restartTries        int64
 	firstRetry          time.Time
-	StartCommand        string   `json:"start_command"`
-	StopCommand         string   `json:"stop_command"`
-	DirectoriesToBackup []string `json:"directories_to_backup"`
+	startedAt           time.Time
+	templateFailed      bool
+	StartCommand        string            `json:"start_command"`
+	StopCommand         string            `json:"stop_command"`
+	DirectoriesToBackup []string          `json:"directories_to_backup"`
+	Port                int64             `json:"port,omitempty"`
+	ProxyRestricted     bool              `json:"proxy_restricted,omitempty"`
+	ProxyExcluded       bool              `json:"proxy_excluded,omitempty"`
+	TemplateVariables   map[string]string `json:"template_variables,omitempty"`
+	TemplateVersion     string            `json:"template_version,omitempty"`
 }
 
 var (
@@ -47,9 +55,10 @@ func DiscoverServers() {
 			serverName := fileNode.Name()
 			serverPath := path.Join(MinecraftServersDirectory, serverName)
 
-			if S3Enabled {
+			var templateErr error
+			if TemplatesEnabled {
 				if !SessionExists(serverName) {
-					UpdateTemplate(serverName)
+					templateErr = UpdateTemplate(serverName)
 				} else {
 					TriggerLogEvent("info", serverName, "Not updating template, server is running")
 				}
@@ -63,12 +72,13 @@ func DiscoverServers() {
 
 			minecraftServer.name = serverName
 			minecraftServer.fullPath = serverPath
+			minecraftServer.templateFailed = templateErr != nil
 
 			minecraftServers[serverName] = minecraftServer
 		}
 	}
 
-	if RedisEnabled && !redisSubscribed {
+	if Bus != nil && !redisSubscribed {
 		ListenForRedisCommands()
 		redisSubscribed = true
 	}
@@ -97,6 +107,11 @@ func StartServer(serverName string) {
 
 	server := minecraftServers[serverName]
 
+	// The template is applied again, it may have been fixed since it failed
+	if server.templateFailed && TemplatesEnabled && !SessionExists(serverName) {
+		server.templateFailed = UpdateTemplate(serverName) != nil
+	}
+
 	startServer(server)
 }
 
@@ -152,6 +167,30 @@ func BackupAllServers() {
 	}
 }
 
+// DiffTemplateServer reports the changes the template of a server with a specified name would apply
+func DiffTemplateServer(serverName string) {
+	TriggerLogEvent("info", serverName, "Comparing template with server files")
+
+	DiffTemplate(serverName)
+}
+
+// DiffTemplateAllServers reports the changes templates would apply to all servers
+func DiffTemplateAllServers() {
+	TriggerLogEvent("info", "rcsm", "Comparing templates with all servers files")
+
+	// Acquire lock on minecraftServers
+	minecraftServersLock.Lock()
+	serverNames := make([]string, 0, len(minecraftServers))
+	for serverName := range minecraftServers {
+		serverNames = append(serverNames, serverName)
+	}
+	minecraftServersLock.Unlock()
+
+	for _, serverName := range serverNames {
+		DiffTemplate(serverName)
+	}
+}
+
 // RunCommandServer restarts a server with a specified name
 func RunCommandServer(serverName string, command string) {
 	// Acquire lock on minecraftServers
@@ -217,6 +256,22 @@ func RestartAllServers() {
 	}
 }
 
+// getServersSnapshot returns a copy of the servers sorted by name, for reporting
+func getServersSnapshot() []MinecraftServer {
+	minecraftServersLock.Lock()
+	servers := make([]MinecraftServer, 0, len(minecraftServers))
+	for _, server := range minecraftServers {
+		servers = append(servers, server)
+	}
+	minecraftServersLock.Unlock()
+
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].name < servers[j].name
+	})
+
+	return servers
+}
+
 func startServer(server MinecraftServer) bool {
 	serverName := server.name
 	isRunning := SessionExists(serverName)
@@ -225,18 +280,43 @@ func startServer(server MinecraftServer) bool {
 		server.running = true
 		server.crashed = false
 		minecraftServers[serverName] = server
+		if ConsoleParsingEnabled {
+			startConsoleParsing(serverName, false)
+		}
+		proxyServerStarted(server, false)
 		return true
 	}
 
+	if server.templateFailed {
+		TriggerEvent(EventServerStartFailed, "severe", serverName, "Not starting, the template could not be applied", EventFields{
+			"server": serverName,
+			"error":  "template could not be applied",
+		})
+		server.running = false
+		server.crashed = true
+		minecraftServers[serverName] = server
+		return false
+	}
+
 	attachCommand, err := SessionCreate(serverName, server.fullPath, server.StartCommand)
 	if err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Could not start: %s", err))
+		TriggerEvent(EventServerStartFailed, "severe", serverName, fmt.Sprintf("Could not start: %s", err), EventFields{
+			"server": serverName,
+			"error":  err.Error(),
+		})
 		server.running = isRunning
 		server.crashed = !isRunning
 	} else {
-		TriggerLogEvent("info", serverName, fmt.Sprintf("Starting server, run \"%s\" to see the console", attachCommand))
+		TriggerEvent(EventServerStarted, "info", serverName, fmt.Sprintf("Starting server, run \"%s\" to see the console", attachCommand), EventFields{
+			"server": serverName,
+		})
 		server.running = true
 		server.crashed = false
+		server.startedAt = time.Now()
+		if ConsoleParsingEnabled {
+			startConsoleParsing(serverName, true)
+		}
+		proxyServerStarted(server, true)
 	}
 
 	minecraftServers[serverName] = server
@@ -252,13 +332,23 @@ func stopServer(server MinecraftServer) bool {
 		return true
 	}
 
+	// Players are sent to another server by the proxy before this one stops
+	unregisterProxyServer(server)
+
+	stopTime := time.Now()
 	err := SessionTerminate(server.name, server.StopCommand, false)
 	if err != nil {
-		TriggerLogEvent("severe", serverName, fmt.Sprintf("Error while stopping: %s", err))
+		TriggerEvent(EventServerStopFailed, "severe", serverName, fmt.Sprintf("Error while stopping: %s", err), EventFields{
+			"server": serverName,
+			"error":  err.Error(),
+		})
 		server.running = isRunning
 		server.crashed = isRunning
 	} else {
-		TriggerLogEvent("info", serverName, "Stopping server")
+		TriggerEvent(EventServerStopped, "info", serverName, "Stopping server", EventFields{
+			"server":   serverName,
+			"duration": time.Since(stopTime).Seconds(),
+		})
 		server.running = false
 		server.crashed = false
 	}
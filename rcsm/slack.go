@@ -0,0 +1,71 @@
+package rcsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SlackWebhookRequest defines the format of a Slack incoming webhook request
+type SlackWebhookRequest struct {
+	Text        string            `json:"text"`
+	Attachments []SlackAttachment `json:"attachments"`
+}
+
+// SlackAttachment defines the format of a Slack message attachment
+type SlackAttachment struct {
+	Color  string       `json:"color"`
+	Text   string       `json:"text"`
+	Fields []SlackField `json:"fields"`
+}
+
+// SlackField defines the format of an attachment field
+type SlackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// SlackNotifier sends events to Slack incoming webhooks
+type SlackNotifier struct{}
+
+// Name returns the name of the notifier
+func (notifier SlackNotifier) Name() string {
+	return "slack"
+}
+
+// Endpoints returns the Slack incoming webhook URLs
+func (notifier SlackNotifier) Endpoints() []string {
+	return splitConfigList(SlackWebhooks)
+}
+
+// Notify sends an event to a Slack incoming webhook
+func (notifier SlackNotifier) Notify(event Event, endpoint string) error {
+	fields := []SlackField{
+		{Title: "Level", Value: event.Level, Short: true},
+		{Title: "Instance", Value: event.Instance, Short: true},
+		{Title: "Server/Service", Value: event.Service, Short: true},
+	}
+
+	if event.Type != EventLog {
+		fields = append(fields, SlackField{Title: "Event", Value: string(event.Type), Short: true})
+	}
+
+	for _, fieldName := range sortedEventFieldNames(event.Fields) {
+		fields = append(fields, SlackField{
+			Title: fieldName,
+			Value: fmt.Sprintf("%v", event.Fields[fieldName]),
+			Short: true,
+		})
+	}
+
+	slackRequest := SlackWebhookRequest{
+		Text: fmt.Sprintf("[%s] %s: %s", event.Level, event.Service, event.Message),
+		Attachments: []SlackAttachment{{
+			Color:  fmt.Sprintf("#%06x", getColorLevel(strings.ToLower(event.Level))),
+			Text:   event.Message,
+			Fields: fields,
+		}},
+	}
+
+	return sendNotifierRequest("POST", endpoint, nil, slackRequest)
+}
@@ -0,0 +1,74 @@
+package rcsm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+)
+
+// serverState defines what rcsm remembers about a server across restarts
+type serverState struct {
+	// TemplateVersion is the version of the last template applied, empty for unversioned templates
+	TemplateVersion string `json:"template_version"`
+	// LastBackup is the Unix timestamp of the last successful backup
+	LastBackup int64 `json:"last_backup,omitempty"`
+}
+
+var (
+	// serverStates maps servers to their state, they are stored in StateFile
+	serverStates     map[string]serverState
+	serverStatesLock sync.Mutex
+)
+
+// getServerState returns the state of a server, and false if nothing was stored for it
+func getServerState(serverName string) (serverState, bool) {
+	serverStatesLock.Lock()
+	defer serverStatesLock.Unlock()
+
+	loadServerStates()
+
+	state, found := serverStates[serverName]
+	return state, found
+}
+
+// updateServerState changes the state of a server and stores it
+func updateServerState(serverName string, update func(state *serverState)) {
+	serverStatesLock.Lock()
+	defer serverStatesLock.Unlock()
+
+	loadServerStates()
+
+	state := serverStates[serverName]
+	update(&state)
+	serverStates[serverName] = state
+
+	jsonStates, err := json.MarshalIndent(serverStates, "", "    ")
+	if err == nil {
+		err = ioutil.WriteFile(StateFile, jsonStates, 0644)
+	}
+	if err != nil {
+		// Log directly, the state is still kept in memory
+		log.Printf("Could not save the state of servers to %s: %s", StateFile, err)
+	}
+}
+
+// loadServerStates reads the states stored by a previous run, serverStatesLock must be held
+func loadServerStates() {
+	if serverStates != nil {
+		return
+	}
+	serverStates = make(map[string]serverState)
+
+	jsonStates, err := ioutil.ReadFile(StateFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(jsonStates, &serverStates)
+	}
+	if err != nil {
+		log.Printf("Could not read the state of servers from %s: %s", StateFile, err)
+	}
+}
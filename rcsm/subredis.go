@@ -1,35 +1,43 @@
 package rcsm
 
 import (
-	"context"
-	"encoding/json"
+	"time"
 )
 
-// RedisAvailable is used to know if redis is ready to receive messages
-var RedisAvailable bool
+// redisAvailable is used to know if redis is ready to receive messages, it's guarded by redisStateLock so read it with isRedisAvailable
+var redisAvailable bool
+
+// Schemas of the messages sent on Redis, so consumers can tell events and commands apart
+const (
+	redisEventSchema   = "rcsm.event"
+	redisCommandSchema = "rcsm.command"
+	// redisSchemaVersion is increased when the format of messages changes in an incompatible way
+	redisSchemaVersion = 1
+)
 
 // RedisMessage defines the structure of the messages we send on Redis
 type RedisMessage struct {
-	Level    string `json:"level"`
-	Instance string `json:"instance"`
-	Service  string `json:"service"`
-	Message  string `json:"message"`
+	Schema    string      `json:"schema"`
+	Version   int         `json:"version"`
+	Level     string      `json:"level"`
+	Instance  string      `json:"instance"`
+	Service   string      `json:"service"`
+	Message   string      `json:"message"`
+	Type      EventType   `json:"type"`
+	Fields    EventFields `json:"fields,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
 }
 
-// SendRedisEvent sends an event on Redis
-func SendRedisEvent(level string, service string, message string) error {
-	redisRequest := RedisMessage{
-		Level:    level,
-		Instance: InstanceName,
-		Service:  service,
-		Message:  message,
+func newRedisMessage(event Event) RedisMessage {
+	return RedisMessage{
+		Schema:    redisEventSchema,
+		Version:   redisSchemaVersion,
+		Level:     event.Level,
+		Instance:  event.Instance,
+		Service:   event.Service,
+		Message:   event.Message,
+		Type:      event.Type,
+		Fields:    event.Fields,
+		Timestamp: event.Timestamp,
 	}
-
-	requestPayload, err := json.Marshal(redisRequest)
-	if err != nil {
-		return err
-	}
-
-	response := RedisClient.Publish(context.TODO(), RedisPubSubChannel, string(requestPayload))
-	return response.Err()
 }
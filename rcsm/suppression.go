@@ -0,0 +1,224 @@
+package rcsm
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDigestLines is the number of lines listed in a digest event
+const maxDigestLines = 20
+
+// eventConditions maps event types clearing a condition to the event types starting it
+var eventConditions = map[EventType][]EventType{
+	EventServerStarted:   {EventServerCrashed, EventBootloop, EventServerStartFailed},
+	EventServerStopped:   {EventServerStopFailed},
+	EventBackupCompleted: {EventBackupFailed},
+	EventTemplateApplied: {EventTemplateFailed, EventTemplateRejected},
+	EventRedisConnected:  {EventRedisUnavailable},
+}
+
+var (
+	activeConditions     = make(map[string]*activeCondition)
+	activeConditionsLock sync.Mutex
+)
+
+// activeCondition defines a problem that has not been resolved yet
+type activeCondition struct {
+	event Event
+	count int64
+}
+
+// eventSuppressor collapses repeated events and batches low priority events for a webhook sink
+type eventSuppressor struct {
+	lock       sync.Mutex
+	sink       *eventSink
+	repeats    map[string]*suppressedEvent
+	digest     []Event
+	lastDigest time.Time
+}
+
+// suppressedEvent defines an event and how many times it was repeated in the current window
+type suppressedEvent struct {
+	event     Event
+	count     int64
+	firstSeen time.Time
+}
+
+func newEventSuppressor(sink *eventSink) *eventSuppressor {
+	suppressor := &eventSuppressor{
+		sink:       sink,
+		repeats:    make(map[string]*suppressedEvent),
+		lastDigest: time.Now(),
+	}
+
+	ticker := time.NewTicker(time.Second)
+	go func() {
+		for range ticker.C {
+			suppressor.flush(false)
+		}
+	}()
+
+	return suppressor
+}
+
+// filter sends an event right away unless it's a repeat or batched in a digest
+func (suppressor *eventSuppressor) filter(event Event) {
+	suppressor.lock.Lock()
+
+	if EventsDigestEnabled && levelRank(event.Level) <= levelRank(EventsDigestMaxLevel) {
+		suppressor.digest = append(suppressor.digest, event)
+		suppressor.lock.Unlock()
+		return
+	}
+
+	if EventsDedupWindowSec > 0 {
+		key := strings.Join([]string{string(event.Type), event.Level, event.Service, event.Message}, "\x00")
+
+		if repeat, found := suppressor.repeats[key]; found {
+			repeat.count++
+			suppressor.lock.Unlock()
+			return
+		}
+
+		suppressor.repeats[key] = &suppressedEvent{
+			event:     event,
+			count:     1,
+			firstSeen: time.Now(),
+		}
+	}
+
+	suppressor.lock.Unlock()
+
+	suppressor.sink.enqueue(event)
+}
+
+// flush sends the repeat counts of expired windows and the digest when due, or everything if forced
+func (suppressor *eventSuppressor) flush(force bool) {
+	var eventsToSend []Event
+	dedupWindow := time.Duration(EventsDedupWindowSec) * time.Second
+
+	suppressor.lock.Lock()
+
+	for key, repeat := range suppressor.repeats {
+		if !force && time.Since(repeat.firstSeen) < dedupWindow {
+			continue
+		}
+		delete(suppressor.repeats, key)
+
+		if repeat.count > 1 {
+			summary := repeat.event
+			summary.Message = fmt.Sprintf("Repeated %d times in %s: %s", repeat.count, time.Since(repeat.firstSeen).Round(time.Second), repeat.event.Message)
+			summary.Fields = copyEventFields(repeat.event.Fields)
+			summary.Fields["count"] = repeat.count
+			summary.Timestamp = time.Now()
+			eventsToSend = append(eventsToSend, summary)
+		}
+	}
+
+	digestInterval := time.Duration(EventsDigestIntervalSec) * time.Second
+	if len(suppressor.digest) > 0 && (force || time.Since(suppressor.lastDigest) >= digestInterval) {
+		eventsToSend = append(eventsToSend, newDigestEvent(suppressor.digest))
+		suppressor.digest = nil
+		suppressor.lastDigest = time.Now()
+	}
+
+	suppressor.lock.Unlock()
+
+	for _, event := range eventsToSend {
+		suppressor.sink.enqueue(event)
+	}
+}
+
+// newDigestEvent creates an event listing batched events, identical events are listed once with a count
+func newDigestEvent(events []Event) Event {
+	var lines []string
+	counts := make(map[string]int)
+
+	for _, event := range events {
+		line := fmt.Sprintf("[%s][%s] %s", event.Level, event.Service, event.Message)
+		if counts[line] == 0 {
+			lines = append(lines, line)
+		}
+		counts[line]++
+	}
+
+	message := fmt.Sprintf("%d event(s) since the last digest", len(events))
+	for i, line := range lines {
+		if i == maxDigestLines {
+			message += fmt.Sprintf("\nand %d more", len(lines)-maxDigestLines)
+			break
+		}
+		if counts[line] > 1 {
+			line += fmt.Sprintf(" (x%d)", counts[line])
+		}
+		message += "\n" + line
+	}
+
+	return Event{
+		Type:      EventDigest,
+		Level:     "INFO",
+		Instance:  InstanceName,
+		Service:   "rcsm",
+		Message:   message,
+		Fields:    EventFields{"count": len(events)},
+		Timestamp: time.Now(),
+	}
+}
+
+// trackEventCondition remembers problems and triggers a resolved event once they are cleared
+func trackEventCondition(event Event) {
+	if !EventsResolvedEnabled {
+		return
+	}
+
+	activeConditionsLock.Lock()
+
+	for _, conditionTypes := range eventConditions {
+		for _, conditionType := range conditionTypes {
+			if conditionType != event.Type {
+				continue
+			}
+
+			key := string(conditionType) + "\x00" + event.Service
+			if condition, found := activeConditions[key]; found {
+				condition.count++
+			} else {
+				activeConditions[key] = &activeCondition{event: event, count: 1}
+			}
+		}
+	}
+
+	var resolved []*activeCondition
+	for _, conditionType := range eventConditions[event.Type] {
+		key := string(conditionType) + "\x00" + event.Service
+		if condition, found := activeConditions[key]; found {
+			resolved = append(resolved, condition)
+			delete(activeConditions, key)
+		}
+	}
+
+	activeConditionsLock.Unlock()
+
+	for _, condition := range resolved {
+		duration := time.Since(condition.event.Timestamp)
+
+		fields := copyEventFields(condition.event.Fields)
+		fields["resolved_type"] = string(condition.event.Type)
+		fields["count"] = condition.count
+		fields["duration"] = duration.Seconds()
+
+		// The level of the condition is kept so the resolution is routed like the problem was
+		TriggerEvent(EventResolved, condition.event.Level, condition.event.Service,
+			fmt.Sprintf("Resolved after %s: %s", duration.Round(time.Second), condition.event.Message), fields)
+	}
+}
+
+func copyEventFields(fields EventFields) EventFields {
+	fieldsCopy := make(EventFields, len(fields))
+	for key, value := range fields {
+		fieldsCopy[key] = value
+	}
+	return fieldsCopy
+}
@@ -0,0 +1,122 @@
+package rcsm
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslogEnterpriseID is used in the structured data ID of syslog messages, such as [rcsm@32473 ...]
+const syslogEnterpriseID = 32473
+
+// syslogParamNameRegex matches characters that are not allowed in syslog structured data parameter names
+var syslogParamNameRegex = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// syslogFacilities maps syslog facility names to their code
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// eventSeverities maps event levels to syslog severities, also used as journald priorities
+var eventSeverities = map[string]int{
+	"DEBUG":  7,
+	"INFO":   6,
+	"WARN":   4,
+	"SEVERE": 3,
+	"FATAL":  2,
+}
+
+var syslogSocket *eventSocket
+
+// eventSocket is a connection to a local or remote log daemon, reconnected after errors
+type eventSocket struct {
+	lock    sync.Mutex
+	network string
+	address string
+	conn    net.Conn
+}
+
+func (socket *eventSocket) write(message []byte) error {
+	socket.lock.Lock()
+	defer socket.lock.Unlock()
+
+	if socket.conn == nil {
+		conn, err := net.Dial(socket.network, socket.address)
+		if err != nil {
+			return err
+		}
+		socket.conn = conn
+	}
+
+	_, err := socket.conn.Write(message)
+	if err != nil {
+		socket.conn.Close()
+		socket.conn = nil
+	}
+
+	return err
+}
+
+// SendSyslogEvent sends an event to syslog using the RFC 5424 format
+func SendSyslogEvent(event Event) error {
+	if syslogSocket == nil {
+		syslogSocket = &eventSocket{network: SyslogNetwork, address: SyslogAddress}
+	}
+
+	message := formatSyslogMessage(event)
+	if SyslogNetwork == "tcp" {
+		// Stream transports use octet counting to separate messages (RFC 6587)
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	return syslogSocket.write([]byte(message))
+}
+
+// formatSyslogMessage formats an event as a RFC 5424 message, fields are sent as structured data
+func formatSyslogMessage(event Event) string {
+	facility, found := syslogFacilities[strings.ToLower(SyslogFacility)]
+	if !found {
+		facility = syslogFacilities["daemon"]
+	}
+	severity, found := eventSeverities[event.Level]
+	if !found {
+		severity = eventSeverities["INFO"]
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	structuredData := fmt.Sprintf("[rcsm@%d instance=\"%s\" server=\"%s\" type=\"%s\"",
+		syslogEnterpriseID, escapeSyslogParamValue(event.Instance), escapeSyslogParamValue(event.Service), escapeSyslogParamValue(string(event.Type)))
+	for _, fieldName := range sortedEventFieldNames(event.Fields) {
+		paramName := syslogParamNameRegex.ReplaceAllString(fieldName, "_")
+		if len(paramName) > 32 {
+			paramName = paramName[:32]
+		}
+		structuredData += fmt.Sprintf(" %s=\"%s\"", paramName, escapeSyslogParamValue(fmt.Sprintf("%v", event.Fields[fieldName])))
+	}
+	structuredData += "]"
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		facility*8+severity,
+		event.Timestamp.Format(time.RFC3339Nano),
+		hostname,
+		SyslogAppName,
+		os.Getpid(),
+		event.Type,
+		structuredData,
+		event.Message,
+	)
+}
+
+func escapeSyslogParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
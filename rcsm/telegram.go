@@ -0,0 +1,53 @@
+package rcsm
+
+import (
+	"fmt"
+	"html"
+)
+
+// telegramAPIURL is the base URL of the Telegram Bot API
+const telegramAPIURL = "https://api.telegram.org"
+
+// TelegramMessage defines the format of a Telegram sendMessage request
+type TelegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"`
+}
+
+// TelegramNotifier sends events to Telegram chats using the Bot API
+type TelegramNotifier struct{}
+
+// Name returns the name of the notifier
+func (notifier TelegramNotifier) Name() string {
+	return "telegram"
+}
+
+// Endpoints returns the Telegram chat IDs
+func (notifier TelegramNotifier) Endpoints() []string {
+	return splitConfigList(TelegramChatIDs)
+}
+
+// Notify sends an event to a Telegram chat
+func (notifier TelegramNotifier) Notify(event Event, endpoint string) error {
+	text := fmt.Sprintf("<b>[%s][%s][%s]</b> %s",
+		html.EscapeString(event.Instance), html.EscapeString(event.Level), html.EscapeString(event.Service), html.EscapeString(event.Message))
+
+	if event.Type != EventLog {
+		text += fmt.Sprintf("\n<b>Event:</b> %s", html.EscapeString(string(event.Type)))
+	}
+
+	for _, fieldName := range sortedEventFieldNames(event.Fields) {
+		text += fmt.Sprintf("\n<b>%s:</b> %s", html.EscapeString(fieldName), html.EscapeString(fmt.Sprintf("%v", event.Fields[fieldName])))
+	}
+
+	message := TelegramMessage{
+		ChatID:    endpoint,
+		Text:      text,
+		ParseMode: "HTML",
+	}
+
+	requestURL := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPIURL, TelegramBotToken)
+
+	return sendNotifierRequest("POST", requestURL, nil, message)
+}
@@ -0,0 +1,143 @@
+package rcsm
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic  = []byte{'P', 'K', 0x03, 0x04}
+)
+
+// templateEntry defines a file or directory read from a template archive
+type templateEntry struct {
+	Name   string
+	IsDir  bool
+	Reader io.Reader
+}
+
+// getTemplateEntryPath returns where an entry of a template is extracted, entries can't be written outside of the server directory
+func getTemplateEntryPath(serverPath string, entryName string) (string, error) {
+	if path.IsAbs(entryName) {
+		return "", fmt.Errorf("Refusing template entry %s, its path is absolute", entryName)
+	}
+
+	serverPath = path.Clean(serverPath)
+	entryPath := path.Join(serverPath, entryName)
+	if entryPath != serverPath && !strings.HasPrefix(entryPath, serverPath+"/") {
+		return "", fmt.Errorf("Refusing template entry %s, it's outside of the server directory", entryName)
+	}
+
+	return entryPath, nil
+}
+
+// walkTemplateArchive calls walkFunc for every file and directory of a .tar, .tar.gz, .tar.zst or .zip archive
+func walkTemplateArchive(templateFile *os.File, walkFunc func(entry templateEntry) error) error {
+	header := make([]byte, len(zstdMagic))
+	headerSize, err := io.ReadFull(templateFile, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	header = header[:headerSize]
+
+	_, err = templateFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	// The format is detected from the contents so templates don't depend on their extension
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		return walkZipArchive(templateFile, walkFunc)
+	case bytes.HasPrefix(header, gzipMagic):
+		decompressed, err := gzip.NewReader(bufio.NewReader(templateFile))
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		return walkTarArchive(decompressed, walkFunc)
+	case bytes.HasPrefix(header, zstdMagic):
+		decompressed, err := zstd.NewReader(bufio.NewReader(templateFile))
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		return walkTarArchive(decompressed, walkFunc)
+	}
+
+	return walkTarArchive(templateFile, walkFunc)
+}
+
+func walkTarArchive(reader io.Reader, walkFunc func(entry templateEntry) error) error {
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil // End of archive
+		}
+		if err != nil {
+			return err
+		}
+
+		// Links and special files are not supported in templates
+		if header.Typeflag != tar.TypeDir && header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		err = walkFunc(templateEntry{
+			Name:   header.Name,
+			IsDir:  header.Typeflag == tar.TypeDir,
+			Reader: archive,
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func walkZipArchive(templateFile *os.File, walkFunc func(entry templateEntry) error) error {
+	fileInfo, err := templateFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	archive, err := zip.NewReader(templateFile, fileInfo.Size())
+	if err != nil {
+		return err
+	}
+
+	for _, file := range archive.File {
+		if strings.HasSuffix(file.Name, "/") {
+			err = walkFunc(templateEntry{Name: file.Name, IsDir: true})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		contents, err := file.Open()
+		if err != nil {
+			return err
+		}
+
+		err = walkFunc(templateEntry{Name: file.Name, Reader: contents})
+		contents.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
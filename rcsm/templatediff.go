@@ -0,0 +1,199 @@
+package rcsm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxTemplateDiffFilesListed is the number of file names listed per category in a template diff event
+const maxTemplateDiffFilesListed = 20
+
+// templateDiff defines the changes a template would apply to a server directory
+type templateDiff struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// DiffTemplate downloads the template of a server and reports what would change without applying it
+func DiffTemplate(serverName string) {
+	if !TemplatesEnabled {
+		TriggerLogEvent("info", serverName, "Templates are disabled, skipping diff")
+		return
+	}
+
+	templateKey, _, found := findTemplate(serverName)
+	if !found {
+		return
+	}
+
+	diff, templateLocation, err := diffTemplate(serverName, templateKey)
+	if err != nil {
+		TriggerLogEvent("severe", serverName, fmt.Sprintf("Could not diff template %s: %s", templateLocation, err))
+		return
+	}
+
+	message := fmt.Sprintf("Template diff with %s: %d added, %d modified, %d deleted",
+		templateLocation, len(diff.Added), len(diff.Modified), len(diff.Deleted))
+	message += formatTemplateDiffFiles("Added", diff.Added)
+	message += formatTemplateDiffFiles("Modified", diff.Modified)
+	message += formatTemplateDiffFiles("Deleted", diff.Deleted)
+
+	TriggerLogEvent("info", serverName, message)
+}
+
+func diffTemplate(serverName string, templateKey string) (templateDiff, string, error) {
+	var diff templateDiff
+	serverPath := path.Join(MinecraftServersDirectory, serverName)
+
+	templateFile, templateLocation, err := downloadTemplateFile(serverName, templateKey)
+	if err != nil {
+		return diff, templateLocation, err
+	}
+	defer os.Remove(templateFile.Name())
+	defer templateFile.Close()
+
+	var variables map[string]string
+	if TemplateVariablesEnabled {
+		variables, err = getTemplateVariables(serverName, serverPath)
+		if err != nil {
+			return diff, templateLocation, err
+		}
+	}
+
+	// Hashes of the files as they would be written by the template
+	templateFiles := make(map[string]string)
+	// Directories are deleted before being extracted, so their other files would be lost
+	templateDirectories := []string{}
+
+	err = walkTemplateArchive(templateFile, func(entry templateEntry) error {
+		fileName := path.Clean(entry.Name)
+		if fileName == "." || fileName == "/" {
+			// The server directory itself is never deleted
+			return nil
+		}
+
+		if entry.IsDir {
+			if _, err := getTemplateEntryPath(serverPath, fileName); err != nil {
+				return err
+			}
+			templateDirectories = append(templateDirectories, fileName)
+			return nil
+		}
+
+		fileName, render := getTemplateOutputName(fileName)
+		if _, err := getTemplateEntryPath(serverPath, fileName); err != nil {
+			return err
+		}
+
+		contents, err := ioutil.ReadAll(entry.Reader)
+		if err != nil {
+			return err
+		}
+
+		if render {
+			// The template would fail to apply, so the diff would be meaningless
+			rendered, err := renderTemplateVariables(fileName, contents, variables)
+			if err != nil {
+				return fmt.Errorf("Could not substitute variables in %s: %s", fileName, err)
+			}
+			contents = rendered
+		}
+
+		templateFiles[fileName] = hashBytes(contents)
+		return nil
+	})
+	if err != nil {
+		return diff, templateLocation, err
+	}
+
+	for fileName, templateHash := range templateFiles {
+		liveHash, err := hashFile(path.Join(serverPath, fileName))
+		if os.IsNotExist(err) {
+			diff.Added = append(diff.Added, fileName)
+		} else if err != nil || liveHash != templateHash {
+			// Unreadable files, such as directories replaced by a file, are overwritten as well
+			diff.Modified = append(diff.Modified, fileName)
+		}
+	}
+
+	for _, directory := range templateDirectories {
+		directoryPath := path.Join(serverPath, directory)
+		filepath.Walk(directoryPath, func(file string, fileInfo os.FileInfo, err error) error {
+			if err != nil || fileInfo.IsDir() {
+				return nil
+			}
+
+			fileName := strings.TrimPrefix(file[len(serverPath):], "/")
+			if _, found := templateFiles[fileName]; !found {
+				diff.Deleted = append(diff.Deleted, fileName)
+			}
+			return nil
+		})
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Modified)
+	diff.Deleted = sortUniqueStrings(diff.Deleted)
+
+	return diff, templateLocation, nil
+}
+
+func formatTemplateDiffFiles(category string, fileNames []string) string {
+	if len(fileNames) == 0 {
+		return ""
+	}
+
+	listed := fileNames
+	if len(listed) > maxTemplateDiffFilesListed {
+		listed = listed[:maxTemplateDiffFilesListed]
+	}
+
+	formatted := fmt.Sprintf("\n%s: %s", category, strings.Join(listed, ", "))
+	if len(fileNames) > len(listed) {
+		formatted += fmt.Sprintf(" and %d more", len(fileNames)-len(listed))
+	}
+
+	return formatted
+}
+
+func sortUniqueStrings(values []string) []string {
+	sort.Strings(values)
+
+	var unique []string
+	for i, value := range values {
+		if i == 0 || values[i-1] != value {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+func hashBytes(contents []byte) string {
+	hash := sha256.Sum256(contents)
+	return hex.EncodeToString(hash[:])
+}
+
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,249 @@
+package rcsm
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// templateSignatureExtension is appended to the template key to find its detached signature
+const templateSignatureExtension = ".minisig"
+
+var (
+	// minisign algorithm for signatures of the whole file
+	minisignAlgorithmPure = []byte("Ed")
+	// minisign algorithm for signatures of the BLAKE2b-512 hash of the file
+	minisignAlgorithmHashed = []byte("ED")
+)
+
+// templateSignatureError is returned when a template is refused because of its signature
+type templateSignatureError struct {
+	error
+}
+
+// templatePublicKey defines a trusted key used to verify templates
+type templatePublicKey struct {
+	keyID     []byte
+	publicKey ed25519.PublicKey
+}
+
+// templateSignature defines a detached signature of a template
+type templateSignature struct {
+	algorithm       []byte
+	keyID           []byte
+	signature       []byte
+	trustedComment  string
+	globalSignature []byte
+}
+
+// verifyTemplateSignature checks the detached signature of a downloaded template against the trusted keys
+func verifyTemplateSignature(templateKey string, templateFile *os.File) error {
+	trustedKeys, err := parseTemplatePublicKeys(TemplateTrustedKeys)
+	if err != nil {
+		return err
+	}
+	if len(trustedKeys) == 0 {
+		return fmt.Errorf("No trusted key set in TEMPLATE_TRUSTED_KEYS")
+	}
+
+	signatureFile, err := ioutil.TempFile("", "rcsm-template-signature")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(signatureFile.Name())
+	defer signatureFile.Close()
+
+	source := getTemplateSource()
+	signatureKey := templateKey + templateSignatureExtension
+
+	exists, err := source.Exists(signatureKey)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Template is not signed, %s is missing", source.Location(signatureKey))
+	}
+
+	err = source.Download(signatureKey, signatureFile)
+	if err != nil {
+		return err
+	}
+
+	_, err = signatureFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	rawSignature, err := ioutil.ReadAll(signatureFile)
+	if err != nil {
+		return err
+	}
+
+	signature, err := parseTemplateSignature(string(rawSignature))
+	if err != nil {
+		return err
+	}
+
+	// The trusted comment binds the signature to the template, so it can't be used for another server or version
+	if signature.globalSignature == nil {
+		return fmt.Errorf("Signature has no trusted comment, sign the template with minisign")
+	}
+	signedFile := getTrustedCommentFile(signature.trustedComment)
+	if signedFile != templateKey && signedFile != path.Base(templateKey) {
+		return fmt.Errorf("Signature was made for `%s`, not for %s", signedFile, templateKey)
+	}
+
+	// Legacy signatures are made on the whole file, they would have to be read in memory
+	if !bytes.Equal(signature.algorithm, minisignAlgorithmHashed) {
+		return fmt.Errorf("Legacy signatures of the whole file are not supported, sign the template again with minisign 0.8 or later")
+	}
+
+	knownKey := false
+	for _, trustedKey := range trustedKeys {
+		if trustedKey.keyID == nil || bytes.Equal(signature.keyID, trustedKey.keyID) {
+			knownKey = true
+		}
+	}
+	if !knownKey {
+		return fmt.Errorf("Signature was made with key %X, which is not in TEMPLATE_TRUSTED_KEYS", reverseBytes(signature.keyID))
+	}
+
+	_, err = templateFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	defer templateFile.Seek(0, io.SeekStart)
+
+	hash, _ := blake2b.New512(nil)
+	_, err = io.Copy(hash, templateFile)
+	if err != nil {
+		return err
+	}
+	hashedMessage := hash.Sum(nil)
+
+	for _, trustedKey := range trustedKeys {
+		if trustedKey.keyID != nil && !bytes.Equal(signature.keyID, trustedKey.keyID) {
+			continue
+		}
+
+		if !ed25519.Verify(trustedKey.publicKey, hashedMessage, signature.signature) {
+			continue
+		}
+
+		// The trusted comment is signed as well so it can't be tampered with
+		globalMessage := append(append([]byte{}, signature.signature...), []byte(signature.trustedComment)...)
+		if !ed25519.Verify(trustedKey.publicKey, globalMessage, signature.globalSignature) {
+			return fmt.Errorf("Invalid trusted comment signature")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("Invalid signature")
+}
+
+// getTrustedCommentFile returns the file named in a trusted comment, such as test1.tar for "timestamp:1690000000\tfile:test1.tar\thashed"
+func getTrustedCommentFile(trustedComment string) string {
+	for _, field := range strings.Fields(trustedComment) {
+		// Some implementations of minisign use filename instead of file
+		for _, prefix := range []string{"file:", "filename:"} {
+			if strings.HasPrefix(field, prefix) {
+				return strings.TrimPrefix(field, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+// reverseBytes returns a reversed copy, minisign displays key IDs as little endian numbers
+func reverseBytes(input []byte) []byte {
+	reversed := make([]byte, len(input))
+	for i, value := range input {
+		reversed[len(input)-1-i] = value
+	}
+	return reversed
+}
+
+// parseTemplatePublicKeys parses minisign public keys, or raw base64 ed25519 public keys, separated by semicolons
+func parseTemplatePublicKeys(rawKeys string) ([]templatePublicKey, error) {
+	var publicKeys []templatePublicKey
+
+	for _, rawKey := range splitConfigList(rawKeys) {
+		decodedKey, err := base64.StdEncoding.DecodeString(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted key `%s`: %s", rawKey, err)
+		}
+
+		switch len(decodedKey) {
+		case ed25519.PublicKeySize:
+			publicKeys = append(publicKeys, templatePublicKey{publicKey: decodedKey})
+		case 2 + 8 + ed25519.PublicKeySize:
+			if !bytes.Equal(decodedKey[:2], minisignAlgorithmPure) {
+				return nil, fmt.Errorf("Unsupported algorithm for trusted key `%s`", rawKey)
+			}
+			publicKeys = append(publicKeys, templatePublicKey{
+				keyID:     decodedKey[2:10],
+				publicKey: decodedKey[10:],
+			})
+		default:
+			return nil, fmt.Errorf("Invalid trusted key `%s`: unexpected length", rawKey)
+		}
+	}
+
+	return publicKeys, nil
+}
+
+// parseTemplateSignature parses a minisign signature file, or a raw base64 ed25519 signature which is refused since it has no trusted comment
+func parseTemplateSignature(rawSignature string) (templateSignature, error) {
+	var signature templateSignature
+	var lines []string
+
+	for _, line := range strings.Split(rawSignature, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "untrusted comment:") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return signature, fmt.Errorf("Empty signature")
+	}
+
+	decodedSignature, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return signature, fmt.Errorf("Invalid signature: %s", err)
+	}
+
+	switch len(decodedSignature) {
+	case ed25519.SignatureSize:
+		signature.algorithm = minisignAlgorithmPure
+		signature.signature = decodedSignature
+		return signature, nil
+	case 2 + 8 + ed25519.SignatureSize:
+		signature.algorithm = decodedSignature[:2]
+		signature.keyID = decodedSignature[2:10]
+		signature.signature = decodedSignature[10:]
+	default:
+		return signature, fmt.Errorf("Invalid signature: unexpected length")
+	}
+
+	if !bytes.Equal(signature.algorithm, minisignAlgorithmPure) && !bytes.Equal(signature.algorithm, minisignAlgorithmHashed) {
+		return signature, fmt.Errorf("Unsupported signature algorithm `%s`", signature.algorithm)
+	}
+
+	if len(lines) >= 3 && strings.HasPrefix(lines[1], "trusted comment:") {
+		signature.trustedComment = strings.TrimPrefix(strings.TrimPrefix(lines[1], "trusted comment:"), " ")
+		signature.globalSignature, err = base64.StdEncoding.DecodeString(lines[2])
+		if err != nil || len(signature.globalSignature) != ed25519.SignatureSize {
+			return signature, fmt.Errorf("Invalid trusted comment signature")
+		}
+	}
+
+	return signature, nil
+}
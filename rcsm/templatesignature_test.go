@@ -0,0 +1,86 @@
+package rcsm
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Vectors created with minisign -Sm lobby.tar, which makes prehashed signatures by default
+const (
+	testTemplateContents  = "server-port={{.Port}}\n"
+	testTemplatePublicKey = "RWQSFyh/b+7xFmZgcgt/RYyFJhOGQYF2QhIJcPqshDKmLqShvAkuXFH8"
+	testTemplateOtherKey  = "RWTjTGdBHxP/vcyqSJe68WrJRVOIF2XWzgLwSKntuUKDIqkXaAgM/lq/"
+	testTemplateSignature = "RUQSFyh/b+7xFrprRywKKQI5usq4w8jGdHQ0CjHIkihaPOzA7qv/l8xOI6E8R3okVSOBkd9VtNuxI6Na84PMNk9RtURdNrLT0ww="
+	testTrustedComment    = "timestamp:1792418299\tfilename:lobby.tar"
+	testGlobalSignature   = "7pyG58LektjZD764GlCRyLK08ZjGj5bG39VzCAi1Aj5tpl6GbovblUnG3UthE8dDkFwi/yVVhtgyxxmqwElJDA=="
+)
+
+// withSignatureAlgorithm returns the signature of the vectors with another algorithm
+func withSignatureAlgorithm(algorithm string) string {
+	decoded, _ := base64.StdEncoding.DecodeString(testTemplateSignature)
+	copy(decoded, algorithm)
+	return base64.StdEncoding.EncodeToString(decoded)
+}
+
+// verifyTestTemplate stores a template and its signature in a local source, then verifies it
+func verifyTestTemplate(t *testing.T, contents string, trustedKeys string, signature string, trustedComment string) error {
+	directory := t.TempDir()
+	rawSignature := "untrusted comment: signature from minisign secret key\n" + signature + "\ntrusted comment: " + trustedComment + "\n" + testGlobalSignature + "\n"
+
+	err := ioutil.WriteFile(filepath.Join(directory, "lobby.tar"), []byte(contents), 0644)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(directory, "lobby.tar.minisig"), []byte(rawSignature), 0644)
+	}
+	if err != nil {
+		t.Fatalf("Could not write the template: %s", err)
+	}
+
+	templateSource = LocalTemplateSource{Path: directory}
+	TemplateTrustedKeys = trustedKeys
+	t.Cleanup(func() { templateSource = nil })
+
+	templateFile, err := os.Open(filepath.Join(directory, "lobby.tar"))
+	if err != nil {
+		t.Fatalf("Could not open the template: %s", err)
+	}
+	defer templateFile.Close()
+
+	return verifyTemplateSignature("lobby.tar", templateFile)
+}
+
+func TestVerifyTemplateSignature(t *testing.T) {
+	tests := []struct {
+		name           string
+		contents       string
+		trustedKeys    string
+		signature      string
+		trustedComment string
+		expectedError  string
+	}{
+		{"valid signature", testTemplateContents, testTemplatePublicKey, testTemplateSignature, testTrustedComment, ""},
+		{"tampered archive", "server-port=25566\n", testTemplatePublicKey, testTemplateSignature, testTrustedComment, "Invalid signature"},
+		{"tampered trusted comment", testTemplateContents, testTemplatePublicKey, testTemplateSignature, "timestamp:1892418299\tfilename:lobby.tar", "Invalid trusted comment signature"},
+		{"unknown key ID", testTemplateContents, testTemplateOtherKey, testTemplateSignature, testTrustedComment, "not in TEMPLATE_TRUSTED_KEYS"},
+		{"legacy algorithm", testTemplateContents, testTemplatePublicKey, withSignatureAlgorithm("Ed"), testTrustedComment, "Legacy signatures"},
+		{"unknown algorithm", testTemplateContents, testTemplatePublicKey, withSignatureAlgorithm("XX"), testTrustedComment, "Unsupported signature algorithm"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyTestTemplate(t, test.contents, test.trustedKeys, test.signature, test.trustedComment)
+			if test.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Expected a valid signature, got: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Fatalf("Expected an error containing %q, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package rcsm
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// templateExtensions are the supported template formats, in order of preference
+var templateExtensions = []string{".tar", ".tar.gz", ".tgz", ".tar.zst", ".zip"}
+
+var (
+	templateSource     TemplateSource
+	templateSourceLock sync.Mutex
+)
+
+// TemplateSource defines where server templates are downloaded from
+type TemplateSource interface {
+	// Exists checks if a template object exists in the source
+	Exists(key string) (bool, error)
+	// Download copies a template object to a file
+	Download(key string, destination *os.File) error
+	// Location returns a human readable location of a template object
+	Location(key string) string
+}
+
+// S3TemplateSource downloads templates from an S3 bucket
+type S3TemplateSource struct {
+	Bucket string
+}
+
+// Exists checks if a template object exists in the bucket
+func (source S3TemplateSource) Exists(key string) (bool, error) {
+	client, _ := getS3Client()
+	_, err := client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(source.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if requestFailure, ok := err.(awserr.RequestFailure); ok && requestFailure.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Download copies a template object from the bucket to a file
+func (source S3TemplateSource) Download(key string, destination *os.File) error {
+	_, downloader := getS3Client()
+
+	_, err := downloader.Download(destination,
+		&s3.GetObjectInput{
+			Bucket: aws.String(source.Bucket),
+			Key:    aws.String(key),
+		})
+	return err
+}
+
+// Location returns the S3 URL of a template object
+func (source S3TemplateSource) Location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", source.Bucket, key)
+}
+
+// LocalTemplateSource reads templates from a local directory, or from a single archive used by every server
+type LocalTemplateSource struct {
+	Path string
+}
+
+// Exists checks if a template archive or directory exists
+func (source LocalTemplateSource) Exists(key string) (bool, error) {
+	_, err := os.Stat(source.resolve(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Download copies a template archive to a file, template directories are packed as a tar archive
+func (source LocalTemplateSource) Download(key string, destination *os.File) error {
+	templatePath := source.resolve(key)
+
+	fileInfo, err := os.Stat(templatePath)
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.IsDir() {
+		return packTemplateDirectory(templatePath, destination)
+	}
+
+	templateFile, err := os.Open(templatePath)
+	if err != nil {
+		return err
+	}
+	defer templateFile.Close()
+
+	_, err = io.Copy(destination, templateFile)
+	return err
+}
+
+// Location returns the path of a template archive or directory
+func (source LocalTemplateSource) Location(key string) string {
+	return source.resolve(key)
+}
+
+func (source LocalTemplateSource) resolve(key string) string {
+	fileInfo, err := os.Stat(source.Path)
+	if err == nil && !fileInfo.IsDir() {
+		// A single archive is used for every server
+		return source.Path
+	}
+	return path.Join(source.Path, key)
+}
+
+// HTTPTemplateSource downloads templates from a plain HTTP(S) server, such as an internal mirror
+type HTTPTemplateSource struct {
+	BaseURL string
+}
+
+// Exists checks if a template object exists on the HTTP server
+func (source HTTPTemplateSource) Exists(key string) (bool, error) {
+	response, err := source.client().Head(source.Location(key))
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return false, nil
+	case response.StatusCode < 200 || response.StatusCode >= 300:
+		return false, fmt.Errorf("Unexpected status %s", response.Status)
+	}
+
+	return true, nil
+}
+
+// Download copies a template object from the HTTP server to a file
+func (source HTTPTemplateSource) Download(key string, destination *os.File) error {
+	response, err := source.client().Get(source.Location(key))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected status %s", response.Status)
+	}
+
+	_, err = io.Copy(destination, response.Body)
+	return err
+}
+
+// Location returns the URL of a template object
+func (source HTTPTemplateSource) Location(key string) string {
+	return strings.TrimSuffix(source.BaseURL, "/") + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+// client returns an HTTP client with a timeout, templates are downloaded while servers are locked
+func (source HTTPTemplateSource) client() *http.Client {
+	return &http.Client{Timeout: time.Duration(TemplateHTTPTimeoutSec) * time.Second}
+}
+
+func getTemplateSource() TemplateSource {
+	templateSourceLock.Lock()
+	defer templateSourceLock.Unlock()
+
+	if templateSource == nil {
+		switch strings.ToLower(TemplateSourceType) {
+		case "s3":
+			templateSource = S3TemplateSource{Bucket: S3Bucket}
+		case "local":
+			templateSource = LocalTemplateSource{Path: TemplateLocalPath}
+		case "http":
+			templateSource = HTTPTemplateSource{BaseURL: TemplateHTTPURL}
+		default:
+			TriggerLogEvent("fatal", "setup", fmt.Sprintf("Unknown template source `%s`", TemplateSourceType))
+			os.Exit(1)
+		}
+	}
+	return templateSource
+}
+
+// getTemplateVersion returns the template version a server is pinned to, empty if it's not pinned
+func getTemplateVersion(serverName string) string {
+	minecraftServer, err := readConfig(path.Join(MinecraftServersDirectory, serverName))
+	if err == nil && minecraftServer.TemplateVersion != "" {
+		return minecraftServer.TemplateVersion
+	}
+	return TemplateVersion
+}
+
+// templateKeyBase returns the key of the template of a server without its extension, such as "prod/lobby-v12"
+func templateKeyBase(serverName string, version string) string {
+	keyBase := TemplatePrefix + serverName
+
+	if version != "" {
+		keyBase += "-" + version
+	}
+
+	return keyBase
+}
+
+// findTemplate returns the key of the template of a server in the first format found, and its version
+func findTemplate(serverName string) (string, string, bool) {
+	source := getTemplateSource()
+	version := getTemplateVersion(serverName)
+	keyBase := templateKeyBase(serverName, version)
+
+	candidates := []string{}
+	for _, extension := range templateExtensions {
+		candidates = append(candidates, keyBase+extension)
+	}
+	if _, isLocal := source.(LocalTemplateSource); isLocal {
+		// Local templates can also be plain directories
+		candidates = append(candidates, keyBase)
+	}
+
+	for _, key := range candidates {
+		exists, err := source.Exists(key)
+		if err != nil {
+			TriggerLogEvent("severe", serverName, fmt.Sprintf("Unable to check template %s: %s", source.Location(key), err))
+			return "", "", false
+		}
+		if exists {
+			return key, version, true
+		}
+	}
+
+	TriggerLogEvent("warn", serverName, fmt.Sprintf("No template found on %s", source.Location(keyBase)))
+
+	return "", "", false
+}
+
+// packTemplateDirectory writes a tar archive of a template directory
+func packTemplateDirectory(directory string, destination io.Writer) error {
+	archive := tar.NewWriter(destination)
+
+	err := filepath.Walk(directory, func(file string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(directory, file)
+		if err != nil || relativePath == "." {
+			return err
+		}
+
+		// Only files and directories are extracted from templates
+		if !fileInfo.IsDir() && !fileInfo.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(fileInfo, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relativePath)
+		if fileInfo.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if fileInfo.IsDir() {
+			return nil
+		}
+
+		data, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer data.Close()
+
+		_, err = io.Copy(archive, data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
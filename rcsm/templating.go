@@ -0,0 +1,99 @@
+package rcsm
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// templateVariablesSuffix marks files of a template in which variables are substituted, it's removed when they are extracted
+const templateVariablesSuffix = ".tmpl"
+
+// getTemplateOutputName returns the name of a file extracted from a template, and if its variables should be substituted
+func getTemplateOutputName(entryName string) (string, bool) {
+	if !TemplateVariablesEnabled {
+		return entryName, false
+	}
+
+	outputName := strings.TrimSuffix(entryName, templateVariablesSuffix)
+	if outputName != entryName && path.Base(outputName) != "." && !strings.HasSuffix(outputName, "/") {
+		return outputName, true
+	}
+
+	return entryName, isTemplateVariablesFile(entryName)
+}
+
+// isTemplateVariablesFile checks if a file from a template should have its variables substituted
+func isTemplateVariablesFile(fileName string) bool {
+	fileName = path.Clean(fileName)
+
+	for _, pattern := range splitConfigList(TemplateVariablesFiles) {
+		// Patterns without a directory match files in any directory, like .gitignore does
+		nameToMatch := fileName
+		if !strings.Contains(pattern, "/") {
+			nameToMatch = path.Base(fileName)
+		}
+
+		matched, err := path.Match(pattern, nameToMatch)
+		if err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getTemplateVariables returns the variables available to the templates of a server
+func getTemplateVariables(serverName string, serverPath string) (map[string]string, error) {
+	variables := make(map[string]string)
+
+	for _, variable := range splitConfigList(TemplateVariables) {
+		keyValue := strings.SplitN(variable, "=", 2)
+		if len(keyValue) != 2 {
+			return nil, fmt.Errorf("Invalid variable `%s` in TEMPLATE_VARIABLES, expected KEY=value", variable)
+		}
+		variables[strings.TrimSpace(keyValue[0])] = strings.TrimSpace(keyValue[1])
+	}
+
+	minecraftServer, err := readConfig(serverPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range minecraftServer.TemplateVariables {
+		variables[key] = value
+	}
+
+	// Built-in variables always win over custom ones
+	variables["ServerName"] = serverName
+	variables["InstanceName"] = InstanceName
+	if minecraftServer.Port != 0 {
+		variables["Port"] = strconv.FormatInt(minecraftServer.Port, 10)
+	}
+
+	return variables, nil
+}
+
+// renderTemplateVariables substitutes the variables in contents extracted from a template
+func renderTemplateVariables(fileName string, contents []byte, variables map[string]string) ([]byte, error) {
+	missingKey := "missingkey=zero"
+	if TemplateVariablesStrict {
+		missingKey = "missingkey=error"
+	}
+
+	fileTemplate, err := template.New(fileName).Option(missingKey).Parse(string(contents))
+	if err != nil {
+		return nil, err
+	}
+
+	var rendered bytes.Buffer
+	err = fileTemplate.Execute(&rendered, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return rendered.Bytes(), nil
+}
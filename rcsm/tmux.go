@@ -129,3 +129,13 @@ func getSessionName(serverName string) string {
 func getAttachCommand(serverName string) string {
 	return fmt.Sprintf("tmux a -t %s", getSessionName(serverName))
 }
+
+// SessionCapture is used to copy the console output of a session to a file
+func SessionCapture(serverName string, outputPath string) error {
+	sessionName := getSessionName(serverName)
+
+	quotedPath := "'" + strings.ReplaceAll(outputPath, "'", `'\''`) + "'"
+	cmd := exec.Command("tmux", "pipe-pane", "-t", sessionName, "cat >> "+quotedPath)
+
+	return cmd.Run()
+}
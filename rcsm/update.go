@@ -2,6 +2,7 @@ package rcsm
 
 import (
 	"fmt"
+	"os"
 	"syscall"
 	"time"
 
@@ -30,18 +31,42 @@ func StartUpdateChecks() {
 
 func runUpdateChecks() {
 	previous := semver.MustParse(Version)
-	latest, err := selfupdate.UpdateSelf(previous, AutoUpdateRepo)
+	latest, found, err := selfupdate.DetectLatest(AutoUpdateRepo)
 	if err != nil {
 		TriggerLogEvent("warn", "updater", fmt.Sprintf("Could not check for updates: %s", err))
 		return
 	}
 
-	if !previous.Equals(latest.Version) {
-		if ExitOnAutoUpdate {
-			TriggerLogEvent("info", "updater", fmt.Sprintf("Updating rcsm to version %s, stopping...", latest.Version))
-			syscall.Kill(syscall.Getpid(), syscall.SIGINT)
-		} else {
-			TriggerLogEvent("info", "updater", fmt.Sprintf("Updated rcsm to version %s, please restart to apply changes", latest.Version))
-		}
+	if !found || latest.Version.LTE(previous) {
+		return
+	}
+
+	TriggerEvent(EventUpdateAvailable, "info", "updater", fmt.Sprintf("rcsm version %s is available", latest.Version), EventFields{
+		"version":          latest.Version.String(),
+		"previous_version": previous.String(),
+	})
+
+	executable, err := os.Executable()
+	if err != nil {
+		TriggerLogEvent("warn", "updater", fmt.Sprintf("Could not locate rcsm executable: %s", err))
+		return
+	}
+
+	err = selfupdate.UpdateTo(latest.AssetURL, executable)
+	if err != nil {
+		TriggerLogEvent("warn", "updater", fmt.Sprintf("Could not update rcsm: %s", err))
+		return
+	}
+
+	updateFields := EventFields{
+		"version":          latest.Version.String(),
+		"previous_version": previous.String(),
+	}
+
+	if ExitOnAutoUpdate {
+		TriggerEvent(EventUpdateInstalled, "info", "updater", fmt.Sprintf("Updating rcsm to version %s, stopping...", latest.Version), updateFields)
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	} else {
+		TriggerEvent(EventUpdateInstalled, "info", "updater", fmt.Sprintf("Updated rcsm to version %s, please restart to apply changes", latest.Version), updateFields)
 	}
 }